@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"syscall"
 	"time"
+	_ "time/tzdata" // часовые пояса для tz в /stats без системной базы
 
 	"github.com/dayanaadylkhanova/click-counter/internal/app"
 	"github.com/dayanaadylkhanova/click-counter/pkg/config"
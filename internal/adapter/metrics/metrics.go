@@ -0,0 +1,208 @@
+// Package metrics — метрики Prometheus: прием событий, flush агрегатора,
+// длительности запросов к хранилищу, пул соединений и HTTP.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "clicks"
+
+// Metrics — реестр метрик сервиса; сам отдает их в формате Prometheus (http.Handler).
+type Metrics struct {
+	reg     *prometheus.Registry
+	handler http.Handler
+
+	events        *prometheus.CounterVec
+	flushRows     prometheus.Histogram
+	flushSeconds  prometheus.Histogram
+	flushFailures prometheus.Counter
+	storeSeconds  *prometheus.HistogramVec
+	httpSeconds   *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	reg := prometheus.NewRegistry()
+	m := &Metrics{
+		reg:     reg,
+		handler: promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "events_total",
+			Help: "Click and impression events by result (accepted, diverted, rejected).",
+		}, []string{"kind", "result"}),
+		flushRows: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "flush_batch_rows",
+			Help:    "Aggregate rows per flush batch.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+		}),
+		flushSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "flush_duration_seconds",
+			Help:    "Aggregator flush latency.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		flushFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "flush_failures_total",
+			Help: "Failed aggregator flushes.",
+		}),
+		storeSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "store_duration_seconds",
+			Help:    "Store call latency by operation and result.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"op", "result"}),
+		httpSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "code"}),
+	}
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.events, m.flushRows, m.flushSeconds, m.flushFailures, m.storeSeconds, m.httpSeconds,
+	)
+	return m
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) { m.handler.ServeHTTP(w, r) }
+
+// ObserveEvent учитывает событие с итогом result: accepted, diverted (принято, но не учтено
+// по политике окна времени) или rejected.
+func (m *Metrics) ObserveEvent(kind service.EventKind, result string) {
+	k := "click"
+	if kind == service.KindImpression {
+		k = "impression"
+	}
+	m.events.WithLabelValues(k, result).Inc()
+}
+
+// ObserveFlush implements service.FlushObserver
+func (m *Metrics) ObserveFlush(rows int, took time.Duration, err error) {
+	m.flushRows.Observe(float64(rows))
+	m.flushSeconds.Observe(took.Seconds())
+	if err != nil {
+		m.flushFailures.Inc()
+	}
+}
+
+// ObserveHTTP учитывает запрос; route — шаблон маршрута ("/stats/{bannerID}"), а не путь.
+func (m *Metrics) ObserveHTTP(method, route string, status int, took time.Duration) {
+	m.httpSeconds.WithLabelValues(method, route, strconv.Itoa(status)).Observe(took.Seconds())
+}
+
+// CounterFunc регистрирует счетчик, значение которого читается из fn при сборе.
+func (m *Metrics) CounterFunc(name, help string, fn func() int64) {
+	m.reg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace, Name: name, Help: help,
+	}, func() float64 { return float64(fn()) }))
+}
+
+// PendingKeys регистрирует число несохраненных ключей агрегатора по шардам.
+func (m *Metrics) PendingKeys(fn func() []int) {
+	m.reg.MustRegister(&pendingCollector{
+		fn:   fn,
+		desc: prometheus.NewDesc(namespace+"_aggregator_pending_keys", "Pending aggregate keys per shard.", []string{"shard"}, nil),
+	})
+}
+
+// Breaker регистрирует состояние предохранителя flush (0 — closed, 1 — half_open,
+// 2 — open) и число ошибок flush подряд.
+func (m *Metrics) Breaker(fn func() (service.BreakerState, int)) {
+	m.reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace, Name: "flush_breaker_state", Help: "Flush circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	}, func() float64 {
+		switch state, _ := fn(); state {
+		case service.BreakerHalfOpen:
+			return 1
+		case service.BreakerOpen:
+			return 2
+		default:
+			return 0
+		}
+	}))
+	m.reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace, Name: "flush_consecutive_failures", Help: "Flush failures since the last successful flush.",
+	}, func() float64 { _, n := fn(); return float64(n) }))
+}
+
+// Pool регистрирует статистику пула соединений pgx.
+func (m *Metrics) Pool(fn func() *pgxpool.Stat) {
+	m.reg.MustRegister(newPoolCollector(fn))
+}
+
+func (m *Metrics) observeStore(op string, start time.Time, err *error) {
+	result := "ok"
+	if *err != nil {
+		result = "error"
+	}
+	m.storeSeconds.WithLabelValues(op, result).Observe(time.Since(start).Seconds())
+}
+
+type pendingCollector struct {
+	fn   func() []int
+	desc *prometheus.Desc
+}
+
+func (c *pendingCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *pendingCollector) Collect(ch chan<- prometheus.Metric) {
+	for i, n := range c.fn() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), strconv.Itoa(i))
+	}
+}
+
+// poolCollector — метрики pgxpool.Stat на момент сбора.
+type poolCollector struct {
+	fn                           func() *pgxpool.Stat
+	total, idle, acquired, max   *prometheus.Desc
+	acquires, emptyAcquires      *prometheus.Desc
+	acquireSeconds, canceledAcqs *prometheus.Desc
+}
+
+func newPoolCollector(fn func() *pgxpool.Stat) *poolCollector {
+	d := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(namespace+"_db_pool_"+name, help, nil, nil)
+	}
+	return &poolCollector{
+		fn:             fn,
+		total:          d("conns", "Connections in the pool."),
+		idle:           d("idle_conns", "Idle connections."),
+		acquired:       d("acquired_conns", "Connections in use."),
+		max:            d("max_conns", "Maximum pool size."),
+		acquires:       d("acquires_total", "Successful connection acquires."),
+		emptyAcquires:  d("empty_acquires_total", "Acquires that had to wait for a connection."),
+		canceledAcqs:   d("canceled_acquires_total", "Acquires canceled by context."),
+		acquireSeconds: d("acquire_seconds_total", "Total time spent acquiring connections."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.total, c.idle, c.acquired, c.max, c.acquires, c.emptyAcquires, c.canceledAcqs, c.acquireSeconds} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	st := c.fn()
+	gauge := func(d *prometheus.Desc, v int32) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(v))
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.total, st.TotalConns())
+	gauge(c.idle, st.IdleConns())
+	gauge(c.acquired, st.AcquiredConns())
+	gauge(c.max, st.MaxConns())
+	counter(c.acquires, float64(st.AcquireCount()))
+	counter(c.emptyAcquires, float64(st.EmptyAcquireCount()))
+	counter(c.canceledAcqs, float64(st.CanceledAcquireCount()))
+	counter(c.acquireSeconds, st.AcquireDuration().Seconds())
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+)
+
+func TestMetrics_Scrape(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := New()
+	m.PendingKeys(func() []int { return []int{3, 0} })
+	m.CounterFunc("late_events_total", "late", func() int64 { return 7 })
+	m.Breaker(func() (service.BreakerState, int) { return service.BreakerOpen, 5 })
+	m.ObserveEvent(service.KindClick, "accepted")
+	m.ObserveEvent(service.KindImpression, "rejected")
+	m.ObserveFlush(10, 20*time.Millisecond, errors.New("db down"))
+	m.ObserveHTTP(http.MethodPost, "/stats/{bannerID}", http.StatusOK, time.Millisecond)
+
+	reader := service.NewMockStatsReaderPort(ctrl)
+	reader.EXPECT().QueryRange(gomock.Any(), gomock.Any()).Return(nil, nil)
+	if _, err := m.Reader(reader).QueryRange(context.Background(), service.RangeQuery{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`clicks_events_total{kind="click",result="accepted"} 1`,
+		`clicks_events_total{kind="impression",result="rejected"} 1`,
+		`clicks_aggregator_pending_keys{shard="0"} 3`,
+		`clicks_flush_batch_rows_count 1`,
+		`clicks_flush_failures_total 1`,
+		`clicks_late_events_total 7`,
+		`clicks_flush_breaker_state 2`,
+		`clicks_flush_consecutive_failures 5`,
+		`clicks_store_duration_seconds_count{op="query_range",result="ok"} 1`,
+		`clicks_http_request_duration_seconds_count{code="200",method="POST",route="/stats/{bannerID}"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
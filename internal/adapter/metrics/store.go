@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+)
+
+// Writer добавляет к w замер длительности UpsertAggregates и MergeSketches.
+func (m *Metrics) Writer(w service.AggregateWriter) service.AggregateWriter {
+	return &writer{m: m, next: w}
+}
+
+// Reader добавляет к r замер длительности всех запросов чтения.
+func (m *Metrics) Reader(r service.StatsReaderPort) service.StatsReaderPort {
+	return &reader{m: m, next: r}
+}
+
+type writer struct {
+	m    *Metrics
+	next service.AggregateWriter
+}
+
+func (w *writer) UpsertAggregates(ctx context.Context, rows []service.AggregateRow) (err error) {
+	defer w.m.observeStore("upsert_aggregates", time.Now(), &err)
+	return w.next.UpsertAggregates(ctx, rows)
+}
+
+func (w *writer) MergeSketches(ctx context.Context, rows []service.SketchRow) (err error) {
+	defer w.m.observeStore("merge_sketches", time.Now(), &err)
+	return w.next.MergeSketches(ctx, rows)
+}
+
+type reader struct {
+	m    *Metrics
+	next service.StatsReaderPort
+}
+
+func (r *reader) QueryRange(ctx context.Context, q service.RangeQuery) (_ []service.AggregateRow, err error) {
+	defer r.m.observeStore("query_range", time.Now(), &err)
+	return r.next.QueryRange(ctx, q)
+}
+
+func (r *reader) QueryUniques(ctx context.Context, q service.RangeQuery) (_ []service.SketchRow, err error) {
+	defer r.m.observeStore("query_uniques", time.Now(), &err)
+	return r.next.QueryUniques(ctx, q)
+}
+
+func (r *reader) QueryTop(ctx context.Context, q service.TopQuery) (_ []service.BannerTotal, err error) {
+	defer r.m.observeStore("query_top", time.Now(), &err)
+	return r.next.QueryTop(ctx, q)
+}
+
+func (r *reader) ExportRange(ctx context.Context, res service.Resolution, from, to time.Time, fn func(service.AggregateRow) error) (err error) {
+	defer r.m.observeStore("export_range", time.Now(), &err)
+	return r.next.ExportRange(ctx, res, from, to, fn)
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/jackc/pgx/v5"
+)
+
+const bannerColumns = "id, name, target_url, archived_at, created_at, updated_at"
+
+// uniqueViolation — код ошибки Postgres при нарушении уникальности.
+const uniqueViolation = "23505"
+
+func scanBanner(row pgx.Row) (service.Banner, error) {
+	var b service.Banner
+	err := row.Scan(&b.ID, &b.Name, &b.TargetURL, &b.ArchivedAt, &b.CreatedAt, &b.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return b, service.ErrUnknownBanner
+	}
+	return b, err
+}
+
+// ListBanners implements service.BannerStorePort
+func (s *Store) ListBanners(ctx context.Context) ([]service.Banner, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+bannerColumns+" FROM banners ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []service.Banner
+	for rows.Next() {
+		b, err := scanBanner(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// CreateBanner implements service.BannerStorePort
+func (s *Store) CreateBanner(ctx context.Context, b service.Banner) (service.Banner, error) {
+	saved, err := scanBanner(s.pool.QueryRow(ctx,
+		"INSERT INTO banners (id, name, target_url) VALUES ($1, $2, $3) RETURNING "+bannerColumns,
+		b.ID, b.Name, b.TargetURL))
+	if pgCode(err) == uniqueViolation {
+		return service.Banner{}, service.ErrBannerExists
+	}
+	return saved, err
+}
+
+// UpdateBanner implements service.BannerStorePort
+func (s *Store) UpdateBanner(ctx context.Context, b service.Banner) (service.Banner, error) {
+	return scanBanner(s.pool.QueryRow(ctx,
+		"UPDATE banners SET name = $2, target_url = $3, updated_at = now() WHERE id = $1 RETURNING "+bannerColumns,
+		b.ID, b.Name, b.TargetURL))
+}
+
+// ArchiveBanner implements service.BannerStorePort
+func (s *Store) ArchiveBanner(ctx context.Context, id int64) (service.Banner, error) {
+	return scanBanner(s.pool.QueryRow(ctx,
+		"UPDATE banners SET archived_at = COALESCE(archived_at, now()), updated_at = now() WHERE id = $1 RETURNING "+bannerColumns,
+		id))
+}
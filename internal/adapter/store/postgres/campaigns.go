@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	campaignColumns   = "id, name, advertiser, created_at"
+	membershipColumns = "campaign_id, banner_id, valid_from, valid_to"
+)
+
+// foreignKeyViolation — код ошибки Postgres при нарушении внешнего ключа.
+const foreignKeyViolation = "23503"
+
+func scanCampaign(row pgx.Row) (service.Campaign, error) {
+	var c service.Campaign
+	err := row.Scan(&c.ID, &c.Name, &c.Advertiser, &c.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c, service.ErrUnknownCampaign
+	}
+	return c, err
+}
+
+func scanMembership(row pgx.Row) (service.Membership, error) {
+	var m service.Membership
+	err := row.Scan(&m.CampaignID, &m.BannerID, &m.From, &m.To)
+	return m, err
+}
+
+func pgCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// CreateCampaign implements service.CampaignStorePort
+func (s *Store) CreateCampaign(ctx context.Context, c service.Campaign) (service.Campaign, error) {
+	saved, err := scanCampaign(s.pool.QueryRow(ctx,
+		"INSERT INTO campaigns (id, name, advertiser) VALUES ($1, $2, $3) RETURNING "+campaignColumns,
+		c.ID, c.Name, c.Advertiser))
+	if pgCode(err) == uniqueViolation {
+		return service.Campaign{}, service.ErrCampaignExists
+	}
+	return saved, err
+}
+
+// ListCampaigns implements service.CampaignStorePort
+func (s *Store) ListCampaigns(ctx context.Context) ([]service.Campaign, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+campaignColumns+" FROM campaigns ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []service.Campaign
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GetCampaign implements service.CampaignStorePort: кампания с полной историей членства.
+func (s *Store) GetCampaign(ctx context.Context, id int64) (service.Campaign, error) {
+	c, err := scanCampaign(s.pool.QueryRow(ctx, "SELECT "+campaignColumns+" FROM campaigns WHERE id = $1", id))
+	if err != nil {
+		return service.Campaign{}, err
+	}
+	c.Members, err = s.members(ctx,
+		"SELECT "+membershipColumns+" FROM campaign_banners WHERE campaign_id = $1 ORDER BY banner_id, valid_from", id)
+	return c, err
+}
+
+// AddCampaignBanner implements service.CampaignStorePort
+func (s *Store) AddCampaignBanner(ctx context.Context, m service.Membership) (service.Membership, error) {
+	saved, err := scanMembership(s.pool.QueryRow(ctx,
+		"INSERT INTO campaign_banners (campaign_id, banner_id, valid_from) VALUES ($1, $2, $3) RETURNING "+membershipColumns,
+		m.CampaignID, m.BannerID, m.From))
+	switch pgCode(err) {
+	case foreignKeyViolation:
+		return service.Membership{}, service.ErrUnknownCampaign
+	case uniqueViolation:
+		return service.Membership{}, service.ErrAlreadyMember
+	}
+	return saved, err
+}
+
+// EndCampaignBanner implements service.CampaignStorePort
+func (s *Store) EndCampaignBanner(ctx context.Context, campaignID, bannerID int64, at time.Time) (service.Membership, error) {
+	m, err := scanMembership(s.pool.QueryRow(ctx,
+		`UPDATE campaign_banners SET valid_to = $3
+		WHERE campaign_id = $1 AND banner_id = $2 AND valid_to IS NULL AND valid_from <= $3
+		RETURNING `+membershipColumns,
+		campaignID, bannerID, at))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return m, err
+	}
+	if err := s.campaignExists(ctx, campaignID); err != nil {
+		return service.Membership{}, err
+	}
+	return service.Membership{}, service.ErrNotMember
+}
+
+// CampaignMembers implements service.CampaignStorePort
+func (s *Store) CampaignMembers(ctx context.Context, campaignID int64, from, to time.Time) ([]service.Membership, error) {
+	if err := s.campaignExists(ctx, campaignID); err != nil {
+		return nil, err
+	}
+	return s.members(ctx,
+		`SELECT `+membershipColumns+` FROM campaign_banners
+		WHERE campaign_id = $1 AND valid_from < $3 AND (valid_to IS NULL OR valid_to > $2)
+		ORDER BY banner_id, valid_from`,
+		campaignID, from, to)
+}
+
+func (s *Store) campaignExists(ctx context.Context, id int64) error {
+	var ok bool
+	if err := s.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM campaigns WHERE id = $1)", id).Scan(&ok); err != nil {
+		return err
+	}
+	if !ok {
+		return service.ErrUnknownCampaign
+	}
+	return nil
+}
+
+func (s *Store) members(ctx context.Context, sql string, args ...any) ([]service.Membership, error) {
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []service.Membership
+	for rows.Next() {
+		m, err := scanMembership(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
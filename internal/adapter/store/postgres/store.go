@@ -3,14 +3,35 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/dayanaadylkhanova/click-counter/internal/entity"
 	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
+// upsertChunk ограничивает число строк в одном INSERT (лимит 65535 параметров).
+const upsertChunk = 1000
+
+// rollupReconcileWindow — за сколько последних суток Init пересчитывает роллапы из минутных
+// строк: реплики старой версии во время rolling deploy пишут только в banner_clicks.
+const rollupReconcileWindow = 48 * time.Hour
+
+// tables — минутная таблица и роллапы, от мелкой резолюции к крупной,
+// вместе с таблицами HLL-скетчей той же резолюции.
+var tables = []struct {
+	name    string
+	uniques string
+	res     service.Resolution
+}{
+	{"banner_clicks", "banner_uniques", service.ResolutionMinute},
+	{"banner_clicks_hourly", "banner_uniques_hourly", service.ResolutionHour},
+	{"banner_clicks_daily", "banner_uniques_daily", service.ResolutionDay},
+}
+
 type Store struct {
 	pool *pgxpool.Pool
 	log  *zap.Logger
@@ -29,53 +50,336 @@ func (s *Store) Init(ctx context.Context) error {
 CREATE TABLE IF NOT EXISTS banner_clicks (
 	banner_id BIGINT      NOT NULL,
 	ts        TIMESTAMPTZ NOT NULL,
+	dims      JSONB       NOT NULL DEFAULT '{}',
 	cnt       BIGINT      NOT NULL,
-	PRIMARY KEY (banner_id, ts)
+	imps      BIGINT      NOT NULL DEFAULT 0,
+	CONSTRAINT banner_clicks_dims_pkey PRIMARY KEY (banner_id, ts, dims)
 );
 CREATE INDEX IF NOT EXISTS idx_banner_clicks_bid_ts ON banner_clicks (banner_id, ts);
+
+CREATE TABLE IF NOT EXISTS banner_clicks_hourly (
+	banner_id BIGINT      NOT NULL,
+	ts        TIMESTAMPTZ NOT NULL,
+	dims      JSONB       NOT NULL DEFAULT '{}',
+	cnt       BIGINT      NOT NULL,
+	imps      BIGINT      NOT NULL DEFAULT 0,
+	CONSTRAINT banner_clicks_hourly_dims_pkey PRIMARY KEY (banner_id, ts, dims)
+);
+CREATE TABLE IF NOT EXISTS banner_clicks_daily (
+	banner_id BIGINT      NOT NULL,
+	ts        TIMESTAMPTZ NOT NULL,
+	dims      JSONB       NOT NULL DEFAULT '{}',
+	cnt       BIGINT      NOT NULL,
+	imps      BIGINT      NOT NULL DEFAULT 0,
+	CONSTRAINT banner_clicks_daily_dims_pkey PRIMARY KEY (banner_id, ts, dims)
+);
+
+-- Таблицы, созданные до появления измерений и показов: добавить dims в первичный ключ и imps
+DO $$
+DECLARE t TEXT;
+BEGIN
+	FOREACH t IN ARRAY ARRAY['banner_clicks', 'banner_clicks_hourly', 'banner_clicks_daily'] LOOP
+		EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS dims JSONB NOT NULL DEFAULT ''{}''', t);
+		EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS imps BIGINT NOT NULL DEFAULT 0', t);
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = t || '_dims_pkey') THEN
+			EXECUTE format('ALTER TABLE %I DROP CONSTRAINT IF EXISTS %I', t, t || '_pkey');
+			EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I PRIMARY KEY (banner_id, ts, dims)', t, t || '_dims_pkey');
+		END IF;
+	END LOOP;
+END $$;
+
+-- Покрывающие индексы по времени для топа баннеров (/top читает все баннеры диапазона)
+CREATE INDEX IF NOT EXISTS idx_banner_clicks_ts ON banner_clicks (ts) INCLUDE (banner_id, cnt);
+CREATE INDEX IF NOT EXISTS idx_banner_clicks_hourly_ts ON banner_clicks_hourly (ts) INCLUDE (banner_id, cnt);
+CREATE INDEX IF NOT EXISTS idx_banner_clicks_daily_ts ON banner_clicks_daily (ts) INCLUDE (banner_id, cnt);
+
+-- HLL-скетчи посетителей (минутные и роллапы)
+CREATE TABLE IF NOT EXISTS banner_uniques (
+	banner_id BIGINT      NOT NULL,
+	ts        TIMESTAMPTZ NOT NULL,
+	sketch    BYTEA       NOT NULL,
+	PRIMARY KEY (banner_id, ts)
+);
+CREATE TABLE IF NOT EXISTS banner_uniques_hourly (
+	banner_id BIGINT      NOT NULL,
+	ts        TIMESTAMPTZ NOT NULL,
+	sketch    BYTEA       NOT NULL,
+	PRIMARY KEY (banner_id, ts)
+);
+CREATE TABLE IF NOT EXISTS banner_uniques_daily (
+	banner_id BIGINT      NOT NULL,
+	ts        TIMESTAMPTZ NOT NULL,
+	sketch    BYTEA       NOT NULL,
+	PRIMARY KEY (banner_id, ts)
+);
+
+-- Реестр баннеров (адреса перехода для /r/{bannerID}, архивация)
+CREATE TABLE IF NOT EXISTS banners (
+	id          BIGINT      PRIMARY KEY,
+	name        TEXT        NOT NULL DEFAULT '',
+	target_url  TEXT        NOT NULL DEFAULT '',
+	archived_at TIMESTAMPTZ,
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+ALTER TABLE banners ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '';
+ALTER TABLE banners ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+ALTER TABLE banners ALTER COLUMN target_url SET DEFAULT '';
+
+-- Кампании и история членства баннеров: [valid_from, valid_to), valid_to NULL — по сей день
+CREATE TABLE IF NOT EXISTS campaigns (
+	id         BIGINT      PRIMARY KEY,
+	name       TEXT        NOT NULL DEFAULT '',
+	advertiser TEXT        NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS campaign_banners (
+	campaign_id BIGINT      NOT NULL REFERENCES campaigns (id),
+	banner_id   BIGINT      NOT NULL,
+	valid_from  TIMESTAMPTZ NOT NULL,
+	valid_to    TIMESTAMPTZ,
+	PRIMARY KEY (campaign_id, banner_id, valid_from)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_campaign_banners_open ON campaign_banners (campaign_id, banner_id) WHERE valid_to IS NULL;
+CREATE INDEX IF NOT EXISTS idx_campaign_banners_bid ON campaign_banners (banner_id);
+
+-- Первичное заполнение роллапов из минутных данных (только для пустых таблиц);
+-- ON CONFLICT — на случай одновременного старта нескольких реплик
+INSERT INTO banner_clicks_hourly (banner_id, ts, dims, cnt, imps)
+SELECT banner_id, date_trunc('hour', ts AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', dims, SUM(cnt), SUM(imps)
+FROM banner_clicks
+WHERE NOT EXISTS (SELECT 1 FROM banner_clicks_hourly)
+GROUP BY 1, 2, 3
+ON CONFLICT (banner_id, ts, dims) DO NOTHING;
+INSERT INTO banner_clicks_daily (banner_id, ts, dims, cnt, imps)
+SELECT banner_id, date_trunc('day', ts AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', dims, SUM(cnt), SUM(imps)
+FROM banner_clicks
+WHERE NOT EXISTS (SELECT 1 FROM banner_clicks_daily)
+GROUP BY 1, 2, 3
+ON CONFLICT (banner_id, ts, dims) DO NOTHING;
 `
-	_, err := s.pool.Exec(ctx, ddl)
-	return err
+	if _, err := s.pool.Exec(ctx, ddl); err != nil {
+		return err
+	}
+	return s.reconcileRollups(ctx, reconcileFrom(time.Now()))
+}
+
+// reconcileFrom — начало окна пересчета роллапов: граница суток UTC, чтобы окно
+// покрывало часовые и дневные бакеты целиком.
+func reconcileFrom(now time.Time) time.Time {
+	return now.Add(-rollupReconcileWindow).UTC().Truncate(24 * time.Hour)
+}
+
+// reconcileRollups пересчитывает роллапы с from полностью из минутных строк. SHARE-блокировка
+// banner_clicks дожидается начатых записей и не пускает новые до конца пересчета, иначе
+// их инкременты роллапов были бы перезаписаны.
+func (s *Store) reconcileRollups(ctx context.Context, from time.Time) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `LOCK TABLE banner_clicks IN SHARE MODE`); err != nil {
+			return err
+		}
+		for _, t := range tables[1:] {
+			unit := "hour"
+			if t.res == service.ResolutionDay {
+				unit = "day"
+			}
+			sql := fmt.Sprintf(`INSERT INTO %s (banner_id, ts, dims, cnt, imps)
+SELECT banner_id, date_trunc('%s', ts AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', dims, SUM(cnt), SUM(imps)
+FROM banner_clicks
+WHERE ts >= $1
+GROUP BY 1, 2, 3
+ON CONFLICT (banner_id, ts, dims) DO UPDATE SET cnt = EXCLUDED.cnt, imps = EXCLUDED.imps`, t.name, unit)
+			if _, err := tx.Exec(ctx, sql, from); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-// UpsertAggregates implements service.AggregateWriter
+// UpsertAggregates implements service.AggregateWriter.
+// Минутные строки и их роллапы пишутся в одной транзакции.
 func (s *Store) UpsertAggregates(ctx context.Context, rows []service.AggregateRow) error {
 	if len(rows) == 0 {
 		return nil
 	}
-	sql := "INSERT INTO banner_clicks (banner_id, ts, cnt) VALUES "
-	args := make([]any, 0, len(rows)*3)
-	for i, r := range rows {
-		if i > 0 {
-			sql += ","
+	return badBatch(pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, t := range tables {
+			if err := upsertInto(ctx, tx, t.name, rollup(rows, t.res)); err != nil {
+				return err
+			}
 		}
-		o := i*3 + 1
-		sql += fmt.Sprintf("($%d,$%d,$%d)", o, o+1, o+2)
-		args = append(args, r.BannerID, r.TS, r.Cnt)
+		return nil
+	}))
+}
+
+// badBatch помечает ошибки данных (класс 22) и ограничений (класс 23) как
+// service.ErrBadBatch: повтор того же батча не поможет, в отличие от сетевых ошибок.
+func badBatch(err error) error {
+	if code := pgCode(err); strings.HasPrefix(code, "22") || strings.HasPrefix(code, "23") {
+		return fmt.Errorf("%w: %v", service.ErrBadBatch, err)
 	}
-	sql += " ON CONFLICT (banner_id, ts) DO UPDATE SET cnt = banner_clicks.cnt + EXCLUDED.cnt"
-	_, err := s.pool.Exec(ctx, sql, args...)
 	return err
 }
 
-// QueryRange implements service.StatsReaderPort
-func (s *Store) QueryRange(ctx context.Context, bannerID int64, from, to time.Time) ([]entity.Point, error) {
-	const q = `SELECT ts, cnt FROM banner_clicks WHERE banner_id=$1 AND ts >= $2 AND ts < $3 ORDER BY ts`
-	rows, err := s.pool.Query(ctx, q, bannerID, from, to)
+// rollup суммирует строки по началу бакета резолюции res и сортирует результат,
+// чтобы параллельные транзакции блокировали строки в одном порядке.
+func rollup(rows []service.AggregateRow, res service.Resolution) []service.AggregateRow {
+	type k struct {
+		banner int64
+		ts     int64
+		dims   service.Dims
+	}
+	step := res.Step()
+	sums := make(map[k]service.AggregateRow, len(rows))
+	for _, r := range rows {
+		key := k{r.BannerID, r.TS.UTC().Truncate(step).Unix(), r.Dims}
+		sum := sums[key]
+		sum.Cnt += r.Cnt
+		sum.Imps += r.Imps
+		sums[key] = sum
+	}
+	out := make([]service.AggregateRow, 0, len(sums))
+	for key, sum := range sums {
+		out = append(out, service.AggregateRow{BannerID: key.banner, TS: time.Unix(key.ts, 0).UTC(), Dims: key.dims, Cnt: sum.Cnt, Imps: sum.Imps})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].BannerID != out[j].BannerID {
+			return out[i].BannerID < out[j].BannerID
+		}
+		if !out[i].TS.Equal(out[j].TS) {
+			return out[i].TS.Before(out[j].TS)
+		}
+		return out[i].Dims < out[j].Dims
+	})
+	return out
+}
+
+func upsertInto(ctx context.Context, tx pgx.Tx, table string, rows []service.AggregateRow) error {
+	for len(rows) > 0 {
+		n := min(len(rows), upsertChunk)
+		var sql strings.Builder
+		sql.WriteString("INSERT INTO " + table + " (banner_id, ts, dims, cnt, imps) VALUES ")
+		args := make([]any, 0, n*5)
+		for i, r := range rows[:n] {
+			if i > 0 {
+				sql.WriteString(",")
+			}
+			o := i*5 + 1
+			fmt.Fprintf(&sql, "($%d,$%d,$%d,$%d,$%d)", o, o+1, o+2, o+3, o+4)
+			args = append(args, r.BannerID, r.TS, r.Dims.Map(), r.Cnt, r.Imps)
+		}
+		sql.WriteString(" ON CONFLICT (banner_id, ts, dims) DO UPDATE SET cnt = " + table + ".cnt + EXCLUDED.cnt, imps = " + table + ".imps + EXCLUDED.imps")
+		if _, err := tx.Exec(ctx, sql.String(), args...); err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
+
+// tableFor выбирает самую грубую таблицу не крупнее res, на границы бакетов
+// которой ложатся from и to.
+func tableFor(res service.Resolution, from, to time.Time) string {
+	return tables[tableIndex(res, from, to)].name
+}
+
+func tableIndex(res service.Resolution, from, to time.Time) int {
+	for i := len(tables) - 1; i > 0; i-- {
+		t := tables[i]
+		step := t.res.Step()
+		if t.res <= res && from.Equal(from.Truncate(step)) && to.Equal(to.Truncate(step)) {
+			return i
+		}
+	}
+	return 0
+}
+
+// QueryRange implements service.StatsReaderPort. Все баннеры запроса читаются
+// одним запросом (banner_id = ANY).
+func (s *Store) QueryRange(ctx context.Context, q service.RangeQuery) ([]service.AggregateRow, error) {
+	var sql strings.Builder
+	args := []any{q.Banners(), q.From, q.To}
+	sql.WriteString("SELECT banner_id, ts")
+	for _, name := range q.GroupBy {
+		args = append(args, name)
+		fmt.Fprintf(&sql, ", dims->>$%d::text", len(args))
+	}
+	sql.WriteString(", SUM(cnt)::bigint, SUM(imps)::bigint FROM " + tableFor(q.Resolution, q.From.UTC(), q.To.UTC()))
+	sql.WriteString(" WHERE banner_id = ANY($1) AND ts >= $2 AND ts < $3")
+	if q.Filter != "" {
+		args = append(args, q.Filter.Map())
+		fmt.Fprintf(&sql, " AND dims @> $%d::jsonb", len(args))
+	}
+	sql.WriteString(" GROUP BY banner_id, ts")
+	for i := range q.GroupBy {
+		fmt.Fprintf(&sql, ", %d", i+3)
+	}
+	sql.WriteString(" ORDER BY ts, banner_id")
+
+	rows, err := s.pool.Query(ctx, sql.String(), args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var out []entity.Point
+	var out []service.AggregateRow
+	vals := make([]*string, len(q.GroupBy))
+	dest := make([]any, 0, len(q.GroupBy)+4)
 	for rows.Next() {
-		var ts time.Time
-		var cnt int64
-		if err := rows.Scan(&ts, &cnt); err != nil {
+		var row service.AggregateRow
+		dest = append(dest[:0], &row.BannerID, &row.TS)
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		dest = append(dest, &row.Cnt, &row.Imps)
+		if err := rows.Scan(dest...); err != nil {
 			return nil, err
 		}
-		out = append(out, entity.Point{TS: ts.UTC(), V: cnt})
+		row.TS = row.TS.UTC()
+		if len(vals) > 0 {
+			m := make(map[string]string, len(vals))
+			for i, v := range vals {
+				if v != nil {
+					m[q.GroupBy[i]] = *v
+				}
+			}
+			row.Dims = service.MakeDims(m)
+		}
+		out = append(out, row)
 	}
 	return out, rows.Err()
 }
 
+// ExportRange implements service.StatsReaderPort: строки читаются из курсора
+// по одной и сразу передаются в fn.
+func (s *Store) ExportRange(ctx context.Context, res service.Resolution, from, to time.Time, fn func(service.AggregateRow) error) error {
+	rows, err := s.pool.Query(ctx, "SELECT banner_id, ts, dims, cnt, imps FROM "+tableFor(res, from.UTC(), to.UTC())+
+		" WHERE ts >= $1 AND ts < $2 ORDER BY ts, banner_id", from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			row  service.AggregateRow
+			dims map[string]string
+		)
+		if err := rows.Scan(&row.BannerID, &row.TS, &dims, &row.Cnt, &row.Imps); err != nil {
+			return err
+		}
+		row.TS = row.TS.UTC()
+		row.Dims = service.MakeDims(dims)
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Ping implements service.PingerPort
+func (s *Store) Ping(ctx context.Context) error { return s.pool.Ping(ctx) }
+
+// PoolStat — статистика пула соединений (для метрик).
+func (s *Store) PoolStat() *pgxpool.Stat { return s.pool.Stat() }
+
 func (s *Store) Close() { s.pool.Close() }
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/hyperloglog"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTableFor(t *testing.T) {
+	day := time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		res      service.Resolution
+		from, to time.Time
+		want     string
+	}{
+		{"minute requested", service.ResolutionMinute, day, day.Add(48 * time.Hour), "banner_clicks"},
+		{"day aligned", service.ResolutionDay, day, day.Add(48 * time.Hour), "banner_clicks_daily"},
+		{"day requested, hour aligned", service.ResolutionDay, day.Add(time.Hour), day.Add(48 * time.Hour), "banner_clicks_hourly"},
+		{"hour requested, minute aligned", service.ResolutionHour, day.Add(time.Minute), day.Add(time.Hour), "banner_clicks"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tableFor(tc.res, tc.from, tc.to); got != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRollup_SumsIntoBuckets(t *testing.T) {
+	ts := time.Date(2025, 10, 19, 10, 0, 0, 0, time.UTC)
+	rows := []service.AggregateRow{
+		{BannerID: 2, TS: ts.Add(59 * time.Minute), Cnt: 1},
+		{BannerID: 1, TS: ts.Add(5 * time.Minute), Cnt: 2},
+		{BannerID: 1, TS: ts.Add(30 * time.Minute), Cnt: 3, Imps: 40},
+		{BannerID: 1, TS: ts.Add(45 * time.Minute), Imps: 10},
+		{BannerID: 1, TS: ts.Add(61 * time.Minute), Cnt: 4},
+	}
+	got := rollup(rows, service.ResolutionHour)
+	want := []service.AggregateRow{
+		{BannerID: 1, TS: ts, Cnt: 5, Imps: 50},
+		{BannerID: 1, TS: ts.Add(time.Hour), Cnt: 4},
+		{BannerID: 2, TS: ts, Cnt: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d rows, got %#v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("row %d: expected %#v, got %#v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestRollupSketches_MergesIntoBuckets(t *testing.T) {
+	hour := time.Date(2025, 10, 19, 10, 0, 0, 0, time.UTC)
+	rows := []service.SketchRow{
+		{BannerID: 1, TS: hour.Add(5 * time.Minute), Sketch: mustSketch(t, "a")},
+		{BannerID: 1, TS: hour.Add(7 * time.Minute), Sketch: mustSketch(t, "b")},
+		{BannerID: 1, TS: hour.Add(65 * time.Minute), Sketch: mustSketch(t, "a")},
+	}
+	got, err := rollupSketches(rows, service.ResolutionHour)
+	if err != nil {
+		t.Fatalf("rollup: %v", err)
+	}
+	if len(got) != 2 || !got[0].TS.Equal(hour) || !got[1].TS.Equal(hour.Add(time.Hour)) {
+		t.Fatalf("unexpected buckets: %#v", got)
+	}
+	hll := hyperloglog.New()
+	if err := hll.UnmarshalBinary(got[0].Sketch); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if hll.Estimate() != 2 {
+		t.Fatalf("expected 2 visitors in first hour, got %d", hll.Estimate())
+	}
+}
+
+func mustSketch(t *testing.T, visitor string) []byte {
+	t.Helper()
+	hll := hyperloglog.New()
+	hll.InsertHash(service.VisitorHash(visitor))
+	b, err := hll.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return b
+}
+
+func TestSegments_CoarsestTablesForAlignedMiddle(t *testing.T) {
+	day := time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)
+	from := day.Add(22*time.Hour + 30*time.Minute) // 19.10 22:30
+	to := day.Add(72*time.Hour + 2*time.Hour + 5*time.Minute)
+
+	got := segments(from, to, len(tables)-1)
+	want := []segment{
+		{0, from, day.Add(23 * time.Hour)},
+		{1, day.Add(23 * time.Hour), day.Add(24 * time.Hour)},
+		{2, day.Add(24 * time.Hour), day.Add(72 * time.Hour)},
+		{1, day.Add(72 * time.Hour), day.Add(74 * time.Hour)},
+		{0, day.Add(74 * time.Hour), to},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i].level != want[i].level || !got[i].from.Equal(want[i].from) || !got[i].to.Equal(want[i].to) {
+			t.Fatalf("segment %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	if got := segments(from, from.Add(10*time.Minute), len(tables)-1); len(got) != 1 || got[0].level != 0 {
+		t.Fatalf("short range expected one minute segment, got %v", got)
+	}
+}
+
+func TestBadBatch(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		bad  bool
+	}{
+		{"numeric overflow", fmt.Errorf("upsert: %w", &pgconn.PgError{Code: "22003"}), true},
+		{"check violation", &pgconn.PgError{Code: "23514"}, true},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, false},
+		{"timeout", context.DeadlineExceeded, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := badBatch(tc.err)
+			if got := errors.Is(err, service.ErrBadBatch); got != tc.bad {
+				t.Fatalf("expected bad=%v, got %v (%v)", tc.bad, got, err)
+			}
+			if !tc.bad && err != tc.err {
+				t.Fatalf("expected error unchanged, got %v", err)
+			}
+		})
+	}
+	if badBatch(nil) != nil {
+		t.Fatal("expected nil")
+	}
+}
+
+func TestReconcileFrom_AlignsToUTCDay(t *testing.T) {
+	now := time.Date(2025, 10, 19, 10, 30, 0, 0, time.FixedZone("UTC+5", 5*3600))
+	if got, want := reconcileFrom(now), time.Date(2025, 10, 17, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
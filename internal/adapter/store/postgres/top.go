@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+)
+
+// segment — часть диапазона, читаемая из таблицы tables[level].
+type segment struct {
+	level    int
+	from, to time.Time
+}
+
+// segments разбивает [from, to) на части так, чтобы середина читалась из самого
+// грубого роллапа, а невыровненные края — из более мелких таблиц (не больше 5 частей).
+func segments(from, to time.Time, level int) []segment {
+	if !from.Before(to) {
+		return nil
+	}
+	if level == 0 {
+		return []segment{{0, from, to}}
+	}
+	step := tables[level].res.Step()
+	lo, hi := from.Truncate(step), to.Truncate(step)
+	if lo.Before(from) {
+		lo = lo.Add(step)
+	}
+	if !lo.Before(hi) {
+		return segments(from, to, level-1)
+	}
+	out := segments(from, lo, level-1)
+	out = append(out, segment{level, lo, hi})
+	return append(out, segments(hi, to, level-1)...)
+}
+
+// QueryTop implements service.StatsReaderPort. Диапазон читается по частям из роллапов
+// (индексы по ts), суммы и ранжирование считаются в одном запросе.
+func (s *Store) QueryTop(ctx context.Context, q service.TopQuery) ([]service.BannerTotal, error) {
+	segs := segments(q.From.UTC(), q.To.UTC(), len(tables)-1)
+	if len(segs) == 0 {
+		return nil, nil
+	}
+	include := q.Include
+	if include == nil {
+		include = []int64{}
+	}
+	args := []any{q.Limit, include}
+	parts := make([]string, 0, len(segs))
+	for _, sg := range segs {
+		args = append(args, sg.from, sg.to)
+		parts = append(parts, fmt.Sprintf("SELECT banner_id, cnt FROM %s WHERE ts >= $%d AND ts < $%d",
+			tables[sg.level].name, len(args)-1, len(args)))
+	}
+	sql := `WITH t AS (
+	SELECT banner_id, SUM(cnt)::bigint AS clicks FROM (` + strings.Join(parts, " UNION ALL ") + `) s
+	GROUP BY banner_id HAVING SUM(cnt) > 0
+)
+SELECT banner_id, clicks FROM (
+	SELECT banner_id, clicks, row_number() OVER (ORDER BY clicks DESC, banner_id) AS rn FROM t
+) r WHERE rn <= $1 OR banner_id = ANY($2)`
+
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []service.BannerTotal
+	for rows.Next() {
+		var t service.BannerTotal
+		if err := rows.Scan(&t.BannerID, &t.Clicks); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
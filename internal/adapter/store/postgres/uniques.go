@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/jackc/pgx/v5"
+)
+
+// MergeSketches implements service.AggregateWriter. Для каждой таблицы скетчей
+// недостающие строки вставляются пустыми, затем существующие блокируются,
+// сливаются с новыми на стороне приложения и перезаписываются.
+func (s *Store) MergeSketches(ctx context.Context, rows []service.SketchRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, t := range tables {
+			merged, err := rollupSketches(rows, t.res)
+			if err != nil {
+				return err
+			}
+			for len(merged) > 0 {
+				n := min(len(merged), upsertChunk)
+				if err := mergeInto(ctx, tx, t.uniques, merged[:n]); err != nil {
+					return err
+				}
+				merged = merged[n:]
+			}
+		}
+		return nil
+	})
+}
+
+// rollupSketches сливает скетчи по началу бакета резолюции res и сортирует
+// результат (порядок блокировок, как в rollup).
+func rollupSketches(rows []service.SketchRow, res service.Resolution) ([]service.SketchRow, error) {
+	type k struct {
+		banner int64
+		ts     int64
+	}
+	step := res.Step()
+	merged := make(map[k][]byte, len(rows))
+	for _, r := range rows {
+		key := k{r.BannerID, r.TS.UTC().Truncate(step).Unix()}
+		b, err := service.MergeSketch(merged[key], r.Sketch)
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = b
+	}
+	out := make([]service.SketchRow, 0, len(merged))
+	for key, b := range merged {
+		out = append(out, service.SketchRow{BannerID: key.banner, TS: time.Unix(key.ts, 0).UTC(), Sketch: b})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].BannerID != out[j].BannerID {
+			return out[i].BannerID < out[j].BannerID
+		}
+		return out[i].TS.Before(out[j].TS)
+	})
+	return out, nil
+}
+
+func mergeInto(ctx context.Context, tx pgx.Tx, table string, rows []service.SketchRow) error {
+	ids := make([]int64, len(rows))
+	tss := make([]time.Time, len(rows))
+	for i, r := range rows {
+		ids[i], tss[i] = r.BannerID, r.TS
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO "+table+" (banner_id, ts, sketch)"+
+		" SELECT id, ts, ''::bytea FROM unnest($1::bigint[], $2::timestamptz[]) AS u(id, ts)"+
+		" ORDER BY id, ts ON CONFLICT (banner_id, ts) DO NOTHING", ids, tss); err != nil {
+		return err
+	}
+
+	cur, err := tx.Query(ctx, "SELECT banner_id, ts, sketch FROM "+table+
+		" WHERE (banner_id, ts) IN (SELECT * FROM unnest($1::bigint[], $2::timestamptz[]))"+
+		" ORDER BY banner_id, ts FOR UPDATE", ids, tss)
+	if err != nil {
+		return err
+	}
+	type k struct {
+		banner int64
+		ts     int64
+	}
+	stored := make(map[k][]byte, len(rows))
+	for cur.Next() {
+		var id int64
+		var ts time.Time
+		var b []byte
+		if err := cur.Scan(&id, &ts, &b); err != nil {
+			cur.Close()
+			return err
+		}
+		stored[k{id, ts.Unix()}] = b
+	}
+	cur.Close()
+	if err := cur.Err(); err != nil {
+		return err
+	}
+
+	sketches := make([][]byte, len(rows))
+	for i, r := range rows {
+		b, err := service.MergeSketch(stored[k{r.BannerID, r.TS.Unix()}], r.Sketch)
+		if err != nil {
+			return err
+		}
+		sketches[i] = b
+	}
+	_, err = tx.Exec(ctx, "UPDATE "+table+" AS t SET sketch = u.sketch"+
+		" FROM unnest($1::bigint[], $2::timestamptz[], $3::bytea[]) AS u(id, ts, sketch)"+
+		" WHERE t.banner_id = u.id AND t.ts = u.ts", ids, tss, sketches)
+	return err
+}
+
+// QueryUniques implements service.StatsReaderPort
+func (s *Store) QueryUniques(ctx context.Context, q service.RangeQuery) ([]service.SketchRow, error) {
+	t := tables[tableIndex(q.Resolution, q.From.UTC(), q.To.UTC())]
+	rows, err := s.pool.Query(ctx, "SELECT banner_id, ts, sketch FROM "+t.uniques+
+		" WHERE banner_id = ANY($1) AND ts >= $2 AND ts < $3 AND length(sketch) > 0 ORDER BY ts, banner_id",
+		q.Banners(), q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []service.SketchRow
+	for rows.Next() {
+		var r service.SketchRow
+		if err := rows.Scan(&r.BannerID, &r.TS, &r.Sketch); err != nil {
+			return nil, err
+		}
+		r.TS = r.TS.UTC()
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
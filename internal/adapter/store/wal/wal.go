@@ -0,0 +1,431 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"go.uber.org/zap"
+)
+
+const segmentExt = ".wal"
+
+var ErrClosed = errors.New("wal: closed")
+
+// Log — append-only журнал инкрементов на диске, реализует service.Journal.
+// Журнал состоит из пронумерованных сегментов; запись идет только в последний.
+// Каждая запись сразу уходит в ОС (write без буфера процесса), поэтому падение или
+// OOM-kill процесса ее не теряет. fsync выполняется группами в фоновой горутине: раз
+// в syncEvery или когда накопилось syncBatch записей; без fsync запись теряется только
+// при отказе питания или ядра. Append не ждет fsync и не держит mu во время fsync.
+type Log struct {
+	dir        string
+	log        *zap.Logger
+	syncBatch  int
+	maxRecords int // записей в сегменте до автоматической ротации (0 — без ограничения)
+
+	syncMu sync.Mutex // сериализует fsync и ротацию; берется до mu
+
+	mu      sync.Mutex
+	seq     uint64 // номер активного сегмента
+	f       *os.File
+	buf     []byte
+	written int        // записей в активном сегменте
+	pending int        // записей после последнего fsync
+	closing []*os.File // закрытые ротацией сегменты, ждущие fsync
+
+	kickCh chan struct{}
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func Open(dir string, syncEvery time.Duration, syncBatch int, log *zap.Logger) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	segs, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seq uint64 = 1
+	if len(segs) > 0 {
+		seq = segs[len(segs)-1] + 1
+	}
+	l := &Log{dir: dir, log: log, syncBatch: syncBatch, kickCh: make(chan struct{}, 1), stopCh: make(chan struct{}), doneCh: make(chan struct{})}
+	if err := l.openSegment(seq); err != nil {
+		return nil, err
+	}
+	go l.syncLoop(syncEvery)
+	return l, nil
+}
+
+// SegmentRecords включает ротацию активного сегмента после n записей, чтобы ReplayOldest
+// мог читать журнал небольшими частями. Вызывается до первой записи.
+func (l *Log) SegmentRecords(n int) { l.maxRecords = n }
+
+func (l *Log) segmentPath(seq uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%016d%s", seq, segmentExt))
+}
+
+func (l *Log) openSegment(seq uint64) error {
+	f, err := os.OpenFile(l.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	l.seq, l.f, l.written = seq, f, 0
+	return nil
+}
+
+func (l *Log) syncLoop(every time.Duration) {
+	defer close(l.doneCh)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case <-t.C:
+		case <-l.kickCh:
+		}
+		if err := l.Sync(); err != nil && !errors.Is(err, ErrClosed) {
+			l.log.Warn("wal sync failed", zap.Error(err))
+		}
+	}
+}
+
+// Sync делает fsync активного сегмента и сегментов, закрытых ротацией, без mu:
+// Append в это время продолжает писать.
+func (l *Log) Sync() error {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	l.mu.Lock()
+	if l.f == nil {
+		l.mu.Unlock()
+		return ErrClosed
+	}
+	closing := l.closing
+	l.closing = nil
+	var f *os.File
+	if l.pending > 0 {
+		f = l.f
+		l.pending = 0
+	}
+	l.mu.Unlock()
+	err := syncClose(closing)
+	if err == nil && f != nil {
+		err = f.Sync()
+	}
+	return err
+}
+
+// roll переключает запись на следующий сегмент; старый закрывается при ближайшем fsync.
+// Вызывается под mu.
+func (l *Log) roll() error {
+	old := l.f
+	if err := l.openSegment(l.seq + 1); err != nil {
+		return err
+	}
+	l.closing = append(l.closing, old)
+	l.pending = 0
+	return nil
+}
+
+func syncClose(files []*os.File) error {
+	var err error
+	for _, f := range files {
+		serr := f.Sync()
+		if cerr := f.Close(); serr == nil {
+			serr = cerr
+		}
+		if err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Append implements service.Journal: возвращает номер сегмента, в который попала запись.
+// Накопив syncBatch записей, Append будит фоновый fsync, но не ждет его.
+func (l *Log) Append(ev service.Event) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return 0, ErrClosed
+	}
+	l.buf = encode(l.buf[:0], ev)
+	if _, err := l.f.Write(l.buf); err != nil {
+		return 0, err
+	}
+	seq := l.seq
+	l.written++
+	l.pending++
+	if l.maxRecords > 0 && l.written >= l.maxRecords {
+		if err := l.roll(); err != nil {
+			return seq, err
+		}
+		l.kick()
+	} else if l.syncBatch > 0 && l.pending >= l.syncBatch {
+		l.kick()
+	}
+	return seq, nil
+}
+
+// kick будит фоновый fsync, не дожидаясь его.
+func (l *Log) kick() {
+	select {
+	case l.kickCh <- struct{}{}:
+	default:
+	}
+}
+
+// Rotate implements service.Journal. Пустой активный сегмент не ротируется.
+// Под mu только открывается новый сегмент; fsync и закрытие
+// старых идут после, записи в это время уже попадают в новый сегмент.
+func (l *Log) Rotate() (uint64, error) {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	l.mu.Lock()
+	if l.f == nil {
+		l.mu.Unlock()
+		return 0, ErrClosed
+	}
+	if l.written > 0 {
+		if err := l.roll(); err != nil {
+			l.mu.Unlock()
+			return 0, err
+		}
+	}
+	seq, closing := l.seq, l.closing
+	l.closing = nil
+	l.mu.Unlock()
+	if err := syncClose(closing); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// Commit implements service.Journal
+func (l *Log) Commit(checkpoint uint64) error {
+	segs, err := listSegments(l.dir)
+	if err != nil {
+		return err
+	}
+	for _, seq := range segs {
+		if seq >= checkpoint {
+			break
+		}
+		if err := os.Remove(l.segmentPath(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replay implements service.Journal. Читает все сегменты, кроме активного;
+// оборванная или поврежденная запись завершает чтение своего сегмента.
+func (l *Log) Replay(fn func(ev service.Event)) error {
+	segs, err := listSegments(l.dir)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	active := l.seq
+	l.mu.Unlock()
+	for _, seq := range segs {
+		if seq >= active {
+			break
+		}
+		if err := l.replaySegment(seq, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplayOldest implements service.SpillLog: читает самые старые сегменты, кроме активного,
+// пока не прочитано limit записей; сегмент читается целиком.
+func (l *Log) ReplayOldest(limit int, fn func(ev service.Event)) (uint64, error) {
+	segs, err := listSegments(l.dir)
+	if err != nil {
+		return 0, err
+	}
+	l.mu.Lock()
+	active := l.seq
+	l.mu.Unlock()
+	var read int
+	var checkpoint uint64
+	count := func(ev service.Event) {
+		read++
+		fn(ev)
+	}
+	for _, seq := range segs {
+		if seq >= active || (limit > 0 && read >= limit) {
+			break
+		}
+		if err := l.replaySegment(seq, count); err != nil {
+			return checkpoint, err
+		}
+		checkpoint = seq + 1
+	}
+	return checkpoint, nil
+}
+
+func (l *Log) replaySegment(seq uint64, fn func(ev service.Event)) error {
+	f, err := os.Open(l.segmentPath(seq))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		ev, err := decode(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			l.log.Warn("wal segment truncated", zap.Uint64("segment", seq), zap.Error(err))
+			return nil
+		}
+		fn(ev)
+	}
+}
+
+func (l *Log) Close() error {
+	close(l.stopCh)
+	<-l.doneCh
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := syncClose(l.closing)
+	l.closing = nil
+	if serr := l.f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	if l.written == 0 {
+		_ = os.Remove(l.segmentPath(l.seq))
+	}
+	l.f = nil
+	return err
+}
+
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segs = append(segs, seq)
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return segs, nil
+}
+
+// Формат записи: uvarint(len) | payload | crc32(payload).
+// Payload: varint banner | varint unix-время | varint cnt | uvarint len + dims.
+// Новые поля добавляются в конец, отсутствующие в старых записях поля читаются как нулевые.
+func encode(dst []byte, ev service.Event) []byte {
+	var p [5*binary.MaxVarintLen64 + 128]byte
+	payload := binary.AppendVarint(p[:0], ev.BannerID)
+	payload = binary.AppendVarint(payload, ev.TS.Unix())
+	payload = binary.AppendVarint(payload, ev.Count)
+	payload = binary.AppendUvarint(payload, uint64(len(ev.Dims)))
+	payload = append(payload, ev.Dims...)
+	// Необязательные поля в конце записи: visitor, kind
+	if ev.Visitor != 0 || ev.Kind != service.KindClick {
+		payload = binary.AppendUvarint(payload, ev.Visitor)
+	}
+	if ev.Kind != service.KindClick {
+		payload = append(payload, byte(ev.Kind))
+	}
+	dst = binary.AppendUvarint(dst, uint64(len(payload)))
+	dst = append(dst, payload...)
+	return binary.LittleEndian.AppendUint32(dst, crc32.ChecksumIEEE(payload))
+}
+
+var errCorrupt = errors.New("wal: corrupt record")
+
+func decode(r *bufio.Reader) (service.Event, error) {
+	var row service.Event
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return row, io.EOF
+		}
+		return row, err
+	}
+	if n > 1<<16 {
+		return row, errCorrupt
+	}
+	buf := make([]byte, n+4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return row, err
+	}
+	payload := buf[:n]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(buf[n:]) {
+		return row, errCorrupt
+	}
+	fields := make([]int64, 3)
+	for i := range fields {
+		if len(payload) == 0 {
+			break
+		}
+		v, k := binary.Varint(payload)
+		if k <= 0 {
+			return row, errCorrupt
+		}
+		fields[i], payload = v, payload[k:]
+	}
+	row.BannerID = fields[0]
+	row.TS = time.Unix(fields[1], 0).UTC()
+	row.Count = fields[2]
+	if len(payload) > 0 {
+		n, k := binary.Uvarint(payload)
+		if k <= 0 || uint64(len(payload)-k) < n {
+			return row, errCorrupt
+		}
+		row.Dims = service.Dims(payload[k : k+int(n)])
+		payload = payload[k+int(n):]
+	}
+	if len(payload) > 0 {
+		v, k := binary.Uvarint(payload)
+		if k <= 0 {
+			return row, errCorrupt
+		}
+		row.Visitor = v
+		payload = payload[k:]
+	}
+	if len(payload) > 0 {
+		row.Kind = service.EventKind(payload[0])
+	}
+	return row, nil
+}
@@ -0,0 +1,325 @@
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"go.uber.org/zap"
+)
+
+func row(banner int64, ts time.Time, cnt int64) service.Event {
+	return service.Event{BannerID: banner, TS: ts, Count: cnt}
+}
+
+func replayAll(t *testing.T, l *Log) []service.Event {
+	t.Helper()
+	var got []service.Event
+	if err := l.Replay(func(ev service.Event) { got = append(got, ev) }); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	return got
+}
+
+func TestLog_ReplayAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	l, err := Open(dir, time.Hour, 2, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	want := row(7, now, 1)
+	want.Dims = "country=KZ"
+	want.Visitor = service.VisitorHash("v-1")
+	for i := 0; i < 3; i++ {
+		if _, err := l.Append(want); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	l, err = Open(dir, time.Hour, 2, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	got := replayAll(t, l)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(got))
+	}
+	for _, r := range got {
+		if r != want {
+			t.Fatalf("unexpected record %#v", r)
+		}
+	}
+}
+
+func TestEncode_RoundTripsOptionalFields(t *testing.T) {
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	imp := row(3, now, 2)
+	imp.Kind = service.KindImpression
+	for _, want := range []service.Event{row(3, now, 1), imp} {
+		got, err := decode(bufio.NewReader(bytes.NewReader(encode(nil, want))))
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if got != want {
+			t.Fatalf("expected %#v, got %#v", want, got)
+		}
+	}
+}
+
+func TestLog_CommitDropsOlderSegments(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	l, err := Open(dir, time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = l.Append(row(1, now, 1))
+	cp, err := l.Rotate()
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	_, _ = l.Append(row(2, now, 1))
+	if err := l.Commit(cp); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	l, err = Open(dir, time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	got := replayAll(t, l)
+	if len(got) != 1 || got[0].BannerID != 2 {
+		t.Fatalf("expected only uncommitted banner 2, got %#v", got)
+	}
+}
+
+func TestLog_AppendReportsSegment(t *testing.T) {
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	l, err := Open(t.TempDir(), time.Hour, 1, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer l.Close()
+
+	if seg, err := l.Append(row(1, now, 1)); err != nil || seg != 1 {
+		t.Fatalf("expected segment 1, got %d (%v)", seg, err)
+	}
+	cp, err := l.Rotate()
+	if err != nil || cp != 2 {
+		t.Fatalf("expected checkpoint 2, got %d (%v)", cp, err)
+	}
+	if seg, err := l.Append(row(1, now, 1)); err != nil || seg != cp {
+		t.Fatalf("expected segment %d, got %d (%v)", cp, seg, err)
+	}
+	if err := l.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+}
+
+func TestLog_ReplayOldestReadsWholeSegments(t *testing.T) {
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	l, err := Open(t.TempDir(), time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer l.Close()
+	l.SegmentRecords(2)
+	for i := int64(1); i <= 5; i++ {
+		_, _ = l.Append(row(i, now, 1))
+	}
+
+	// сегменты 1 и 2 по две записи, активный 3 не читается
+	var got []int64
+	cp, err := l.ReplayOldest(3, func(ev service.Event) { got = append(got, ev.BannerID) })
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if cp != 3 || len(got) != 4 || got[3] != 4 {
+		t.Fatalf("expected banners 1-4 up to segment 3, got %v and %d", got, cp)
+	}
+	if err := l.Commit(cp); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if cp, err := l.ReplayOldest(3, func(service.Event) {}); err != nil || cp != 0 {
+		t.Fatalf("expected nothing to replay, got %d (%v)", cp, err)
+	}
+}
+
+func TestLog_AppendWritesThrough(t *testing.T) {
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	l, err := Open(t.TempDir(), time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer l.Close()
+	want := row(5, now, 2)
+	if _, err := l.Append(want); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	// без Sync и Close запись уже в файле: убитый процесс ее не теряет
+	b, err := os.ReadFile(l.segmentPath(l.seq))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	got, err := decode(bufio.NewReader(bytes.NewReader(b)))
+	if err != nil || got != want {
+		t.Fatalf("expected %#v in the segment, got %#v (%v)", want, got, err)
+	}
+}
+
+func TestLog_TornTailIsIgnored(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	l, err := Open(dir, time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = l.Append(row(1, now, 1))
+	_, _ = l.Append(row(1, now, 1))
+	path := l.segmentPath(l.seq)
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	// обрезаем последнюю запись посередине
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if err := os.Truncate(path, fi.Size()-3); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	l, err = Open(dir, time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	if got := replayAll(t, l); len(got) != 1 {
+		t.Fatalf("expected 1 intact record, got %d", len(got))
+	}
+}
+
+// splitWriter принимает только одиночные строки: баннер 1 хранилище отвергает (ErrBadBatch),
+// баннер 3 не записывается из-за сбоя БД, остальные записываются в db.
+type splitWriter struct{ db map[int64]int64 }
+
+func (w *splitWriter) UpsertAggregates(_ context.Context, rows []service.AggregateRow) error {
+	switch {
+	case len(rows) > 1 || rows[0].BannerID == 1:
+		return service.ErrBadBatch
+	case rows[0].BannerID == 3:
+		return errors.New("db down")
+	}
+	w.db[rows[0].BannerID] += rows[0].Cnt
+	return nil
+}
+
+func (w *splitWriter) MergeSketches(context.Context, []service.SketchRow) error { return nil }
+
+func TestLog_ReplayAfterPartialSplitFlush(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	clicks := map[int64]int64{1: 2, 2: 3, 3: 5, 4: 7}
+
+	l, err := Open(dir, time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	w := &splitWriter{db: make(map[int64]int64)}
+	agg := service.NewAggregator(zap.NewNop(), w, 1, time.Hour)
+	if err := agg.UseJournal(l); err != nil {
+		t.Fatalf("use journal: %v", err)
+	}
+	for id, n := range clicks {
+		_ = agg.Add(service.Event{BannerID: id, TS: now, Count: n})
+	}
+	// flush падает на баннере 3, часть строк к этому моменту записана или отброшена
+	agg.Stop(context.Background())
+	poisoned := agg.Poisoned()
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	l, err = Open(dir, time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	replayed := make(map[int64]int64)
+	for _, ev := range replayAll(t, l) {
+		replayed[ev.BannerID] += ev.Count
+	}
+	if replayed[3] != clicks[3] {
+		t.Fatalf("expected unwritten banner 3 to be replayed, got %v", replayed)
+	}
+	for id, n := range clicks {
+		if id == 1 && poisoned == 1 {
+			if replayed[1] != 0 {
+				t.Fatalf("expected dropped banner 1 gone from the journal, got %v", replayed)
+			}
+			continue
+		}
+		if got := w.db[id] + replayed[id]; got != n {
+			t.Fatalf("banner %d: expected %d clicks in db and journal, got %d (db %v, journal %v)", id, n, got, w.db, replayed)
+		}
+	}
+}
+
+func TestLog_ReplaySkipsEvictedMinutes(t *testing.T) {
+	dir := t.TempDir()
+	t0 := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	l, err := Open(dir, time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	agg := service.NewAggregator(zap.NewNop(), nil, 1, time.Hour)
+	agg.Limit(1, service.OverflowDropOldest)
+	if err := agg.UseJournal(l); err != nil {
+		t.Fatalf("use journal: %v", err)
+	}
+	_ = agg.Add(service.Event{BannerID: 1, TS: t0, Count: 3})
+	_ = agg.Inc(1, t0.Add(time.Minute))
+	if clicks, _ := agg.Dropped(); clicks != 3 {
+		t.Fatalf("expected 3 dropped clicks, got %d", clicks)
+	}
+	// падение без flush
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	l, err = Open(dir, time.Hour, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	agg = service.NewAggregator(zap.NewNop(), nil, 1, time.Hour)
+	if err := agg.UseJournal(l); err != nil {
+		t.Fatalf("use journal: %v", err)
+	}
+	rows, _ := agg.Pending([]int64{1}, t0, t0.Add(time.Hour))
+	if len(rows) != 1 || !rows[0].TS.Equal(t0.Add(time.Minute)) || rows[0].Cnt != 1 {
+		t.Fatalf("expected only the kept minute after replay, got %+v", rows)
+	}
+	if keys := agg.PendingKeys(); keys[0] != 1 {
+		t.Fatalf("expected 1 pending key, got %v", keys)
+	}
+}
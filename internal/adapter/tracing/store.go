@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/dayanaadylkhanova/click-counter/internal/adapter/tracing"
+
+// Writer оборачивает запись агрегатов в спаны с размером батча
+// (дочерние к спану flush, см. Flush).
+func Writer(w service.AggregateWriter) service.AggregateWriter { return &writer{next: w} }
+
+// Flush открывает корневой спан "flush" на каждую запись агрегатора: flush идет вне запроса,
+// а части батча, разделенного после ErrBadBatch, должны оказаться в одной трассе.
+func Flush() service.FlushTracer { return flushTracer{} }
+
+type flushTracer struct{}
+
+func (flushTracer) StartFlush(ctx context.Context) (context.Context, func(int, error)) {
+	ctx, span := otel.Tracer(scope).Start(ctx, "flush", trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx, func(rows int, err error) {
+		span.SetAttributes(attribute.Int("clicks.batch_rows", rows))
+		end(span, err)
+	}
+}
+
+// Reader оборачивает чтение агрегатов в спаны (дочерние к спану HTTP-запроса).
+func Reader(r service.StatsReaderPort) service.StatsReaderPort { return &reader{next: r} }
+
+func start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(scope).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attribute.String("db.system", "postgresql"))...))
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func rangeAttrs(q service.RangeQuery) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("clicks.banners", len(q.Banners())),
+		attribute.String("clicks.from", q.From.Format(time.RFC3339)),
+		attribute.String("clicks.to", q.To.Format(time.RFC3339)),
+		attribute.Int("clicks.resolution", int(q.Resolution)),
+		attribute.StringSlice("clicks.group_by", q.GroupBy),
+	}
+}
+
+type writer struct{ next service.AggregateWriter }
+
+func (w *writer) UpsertAggregates(ctx context.Context, rows []service.AggregateRow) (err error) {
+	ctx, span := start(ctx, "UpsertAggregates", attribute.Int("clicks.batch_rows", len(rows)))
+	defer func() { end(span, err) }()
+	return w.next.UpsertAggregates(ctx, rows)
+}
+
+func (w *writer) MergeSketches(ctx context.Context, rows []service.SketchRow) (err error) {
+	ctx, span := start(ctx, "MergeSketches", attribute.Int("clicks.batch_rows", len(rows)))
+	defer func() { end(span, err) }()
+	return w.next.MergeSketches(ctx, rows)
+}
+
+type reader struct{ next service.StatsReaderPort }
+
+func (r *reader) QueryRange(ctx context.Context, q service.RangeQuery) (rows []service.AggregateRow, err error) {
+	ctx, span := start(ctx, "QueryRange", rangeAttrs(q)...)
+	defer func() {
+		span.SetAttributes(attribute.Int("clicks.rows", len(rows)))
+		end(span, err)
+	}()
+	return r.next.QueryRange(ctx, q)
+}
+
+func (r *reader) QueryUniques(ctx context.Context, q service.RangeQuery) (rows []service.SketchRow, err error) {
+	ctx, span := start(ctx, "QueryUniques", rangeAttrs(q)...)
+	defer func() { end(span, err) }()
+	return r.next.QueryUniques(ctx, q)
+}
+
+func (r *reader) QueryTop(ctx context.Context, q service.TopQuery) (_ []service.BannerTotal, err error) {
+	ctx, span := start(ctx, "QueryTop", attribute.Int("clicks.limit", q.Limit))
+	defer func() { end(span, err) }()
+	return r.next.QueryTop(ctx, q)
+}
+
+func (r *reader) ExportRange(ctx context.Context, res service.Resolution, from, to time.Time, fn func(service.AggregateRow) error) (err error) {
+	ctx, span := start(ctx, "ExportRange", attribute.Int("clicks.resolution", int(res)))
+	defer func() { end(span, err) }()
+	return r.next.ExportRange(ctx, res, from, to, fn)
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStoreSpans(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	w := service.NewMockAggregateWriter(ctrl)
+	w.EXPECT().UpsertAggregates(gomock.Any(), gomock.Len(3)).Return(context.DeadlineExceeded)
+	r := service.NewMockStatsReaderPort(ctrl)
+	r.EXPECT().QueryRange(gomock.Any(), gomock.Any()).Return(make([]service.AggregateRow, 2), nil)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "POST /stats/{bannerID}")
+	if _, err := Reader(r).QueryRange(ctx, service.RangeQuery{BannerIDs: []int64{1, 2}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+	fctx, endFlush := Flush().StartFlush(context.Background())
+	err := Writer(w).UpsertAggregates(fctx, make([]service.AggregateRow, 3))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	endFlush(3, err)
+
+	spans := sr.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	query, upsert, flush := spans[0], spans[2], spans[3]
+	if query.Name() != "QueryRange" || query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("QueryRange span is not a child of the request span: %+v", query.Parent())
+	}
+	if !hasAttr(query.Attributes(), attribute.Int("clicks.banners", 2)) || !hasAttr(query.Attributes(), attribute.Int("clicks.rows", 2)) {
+		t.Fatalf("unexpected QueryRange attributes: %v", query.Attributes())
+	}
+	if flush.Name() != "flush" || flush.Parent().IsValid() || flush.Status().Code != codes.Error {
+		t.Fatalf("unexpected flush span: %s %v %v", flush.Name(), flush.Parent(), flush.Status())
+	}
+	if upsert.Name() != "UpsertAggregates" || upsert.Parent().SpanID() != flush.SpanContext().SpanID() || upsert.Status().Code != codes.Error {
+		t.Fatalf("UpsertAggregates span is not a child of the flush span: %s %v %v", upsert.Name(), upsert.Parent(), upsert.Status())
+	}
+	if !hasAttr(upsert.Attributes(), attribute.Int("clicks.batch_rows", 3)) {
+		t.Fatalf("unexpected UpsertAggregates attributes: %v", upsert.Attributes())
+	}
+}
+
+func hasAttr(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package http_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"go.uber.org/zap"
+)
+
+func (s *Server) handleBannerList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := s.banners.List(r.Context(), r.URL.Query().Get("archived") == "true")
+		if err != nil {
+			s.bannerError(w, err)
+			return
+		}
+		resp := entity.BannerList{Banners: make([]entity.Banner, 0, len(list))}
+		for _, b := range list {
+			resp.Banners = append(resp.Banners, bannerDTO(b))
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) handleBannerGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseBannerID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, ok := s.banners.Get(id)
+		if !ok {
+			http.Error(w, service.ErrUnknownBanner.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, bannerDTO(b))
+	}
+}
+
+func (s *Server) handleBannerCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeBanner(w, r)
+		if !ok {
+			return
+		}
+		b, err := s.banners.Create(r.Context(), service.Banner{ID: req.ID, Name: req.Name, TargetURL: req.TargetURL})
+		if err != nil {
+			s.bannerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, bannerDTO(b))
+	}
+}
+
+func (s *Server) handleBannerUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseBannerID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := decodeBanner(w, r)
+		if !ok {
+			return
+		}
+		b, err := s.banners.Update(r.Context(), service.Banner{ID: id, Name: req.Name, TargetURL: req.TargetURL})
+		if err != nil {
+			s.bannerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, bannerDTO(b))
+	}
+}
+
+func (s *Server) handleBannerArchive() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseBannerID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, err := s.banners.Archive(r.Context(), id)
+		if err != nil {
+			s.bannerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, bannerDTO(b))
+	}
+}
+
+func decodeBanner(w http.ResponseWriter, r *http.Request) (entity.BannerRequest, bool) {
+	var req entity.BannerRequest
+	return req, decodeJSON(w, r, &req)
+}
+
+func (s *Server) bannerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidBanner):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrUnknownBanner):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrBannerExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		s.log.Error("banners", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+func bannerDTO(b service.Banner) entity.Banner {
+	return entity.Banner{
+		ID:         b.ID,
+		Name:       b.Name,
+		TargetURL:  b.TargetURL,
+		Archived:   b.Archived(),
+		ArchivedAt: b.ArchivedAt,
+		CreatedAt:  b.CreatedAt,
+		UpdatedAt:  b.UpdatedAt,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,116 @@
+package http_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestHandleBannerCreate(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		storeErr error
+		want     int
+	}{
+		{"created", `{"id":7,"name":"promo","target_url":"https://ads.example/7"}`, nil, http.StatusCreated},
+		{"duplicate", `{"id":7,"name":"promo"}`, service.ErrBannerExists, http.StatusConflict},
+		{"invalid url", `{"id":7,"target_url":"javascript:alert(1)"}`, service.ErrInvalidBanner, http.StatusBadRequest},
+		{"unknown field", `{"id":7,"url":"https://ads.example/7"}`, nil, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			banners := service.NewMockBannerRegistryPort(ctrl)
+			if tc.want != http.StatusBadRequest || tc.storeErr != nil {
+				banners.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, b service.Banner) (service.Banner, error) {
+					return b, tc.storeErr
+				})
+			}
+			srv := NewServer(zap.NewNop(), ":0", nil, nil, nil, nil, VisitorConfig{}, banners, nil, nil, nil)
+			srv.UseAdminToken("secret")
+			req := httptest.NewRequest(http.MethodPost, "/banners", strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			srv.httpSrv.Handler.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, rec.Body)
+			}
+			if tc.want == http.StatusCreated {
+				var b entity.Banner
+				if err := json.NewDecoder(rec.Body).Decode(&b); err != nil || b.ID != 7 || b.Archived {
+					t.Fatalf("unexpected body %+v (%v)", b, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminRoutes_RequireToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		auth  string
+		want  int
+	}{
+		{"disabled", "", "Bearer secret", http.StatusForbidden},
+		{"missing", "secret", "", http.StatusUnauthorized},
+		{"wrong", "secret", "Bearer other", http.StatusUnauthorized},
+	}
+	routes := []struct{ method, path string }{
+		{http.MethodPost, "/banners"},
+		{http.MethodPut, "/banners/7"},
+		{http.MethodPost, "/banners/7/archive"},
+		{http.MethodPost, "/campaigns"},
+		{http.MethodPost, "/campaigns/7/banners"},
+		{http.MethodDelete, "/campaigns/7/banners/3"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// моки без ожиданий: запрос не должен дойти до реестров
+			banners := service.NewMockBannerRegistryPort(ctrl)
+			campaigns := service.NewMockCampaignPort(ctrl)
+			srv := NewServer(zap.NewNop(), ":0", nil, nil, nil, nil, VisitorConfig{}, banners, campaigns, nil, nil)
+			srv.UseAdminToken(tc.token)
+			for _, rt := range routes {
+				req := httptest.NewRequest(rt.method, rt.path, strings.NewReader(`{}`))
+				if tc.auth != "" {
+					req.Header.Set("Authorization", tc.auth)
+				}
+				rec := httptest.NewRecorder()
+				srv.httpSrv.Handler.ServeHTTP(rec, req)
+				if rec.Code != tc.want {
+					t.Fatalf("%s %s: expected %d, got %d", rt.method, rt.path, tc.want, rec.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCounter_UnknownBannerRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	agg := service.NewMockAggregatorPort(ctrl)
+	banners := service.NewMockBannerRegistryPort(ctrl)
+	banners.EXPECT().Resolve(int64(9)).Return(int64(0), service.ErrUnknownBanner)
+
+	srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, service.NewDimensions(nil), VisitorConfig{}, banners, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/counter/9", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
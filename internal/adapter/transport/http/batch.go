@@ -0,0 +1,130 @@
+package http_server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+)
+
+const (
+	maxBatchItems = 10000
+	maxBatchBytes = 8 << 20
+	// defaultMaxItemCount — лимит count элемента батча, если не задан UseMaxItemCount
+	defaultMaxItemCount = 1000
+)
+
+var errTooManyItems = errors.New("too many items")
+
+// UseMaxItemCount ограничивает count одного элемента батча: без лимита один запрос
+// мог бы переполнить суммы int64 или раздуть статистику любого баннера. Вызывается до Start.
+func (s *Server) UseMaxItemCount(n int64) { s.maxItemCount = n }
+
+// handleCounterBatch принимает массив кликов (JSON или NDJSON) и
+// возвращает результат по каждому элементу.
+func (s *Server) handleCounterBatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBatchBytes)
+		ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		var raws []json.RawMessage
+		var err error
+		switch ct {
+		case "application/x-ndjson", "application/ndjson":
+			raws, err = readNDJSON(r)
+		default:
+			dec := json.NewDecoder(r.Body)
+			err = dec.Decode(&raws)
+			if err == nil && len(raws) > maxBatchItems {
+				err = errTooManyItems
+			}
+		}
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.Is(err, errTooManyItems), errors.As(err, &maxErr):
+			http.Error(w, "batch too large", http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		resp := entity.BatchResponse{Results: make([]entity.BatchItemResult, len(raws))}
+		for i, raw := range raws {
+			res := entity.BatchItemResult{Index: i}
+			if err := s.acceptBatchItem(raw, now); err != nil {
+				res.Error = err.Error()
+				resp.Rejected++
+			} else {
+				res.Accepted = true
+				resp.Accepted++
+			}
+			resp.Results[i] = res
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func (s *Server) acceptBatchItem(raw json.RawMessage, now time.Time) error {
+	var item entity.BatchItem
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&item); err != nil {
+		return errors.New("invalid item")
+	}
+	id, err := validateBannerID(item.BannerID.String())
+	if err != nil {
+		return err
+	}
+	switch {
+	case item.Count == 0:
+		item.Count = 1
+	case item.Count < 0:
+		return errors.New("invalid count")
+	case item.Count > s.maxItemCount:
+		return errors.New("count too large")
+	}
+	kind, err := parseKind(item.Kind)
+	if err != nil {
+		return err
+	}
+	ev := service.Event{
+		BannerID: id,
+		TS:       now,
+		Count:    item.Count,
+		Dims:     s.dims.Capture(func(name string) string { return item.Dims[name] }),
+		Visitor:  service.VisitorHash(item.Visitor),
+		Kind:     kind,
+	}
+	if item.TS != "" {
+		if ev.TS, err = parseEventTime(item.TS); err != nil {
+			return errors.New("invalid ts")
+		}
+	}
+	return s.track(ev, item.TS != "")
+}
+
+func readNDJSON(r *http.Request) ([]json.RawMessage, error) {
+	var raws []json.RawMessage
+	sc := bufio.NewScanner(r.Body)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(raws) == maxBatchItems {
+			return nil, errTooManyItems
+		}
+		raws = append(raws, json.RawMessage(bytes.Clone(line)))
+	}
+	return raws, sc.Err()
+}
@@ -0,0 +1,98 @@
+package http_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestHandleCounterBatch(t *testing.T) {
+	ts := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "json array",
+			contentType: "application/json",
+			body:        `[{"banner_id":1,"ts":"2025-10-19T00:29:00Z","count":3,"dims":{"country":"KZ"}},{"banner_id":"2","ts":"2025-10-19T00:29:00Z","kind":"impression"},{"banner_id":0},{"banner_id":1,"count":-1}]`,
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			body: `{"banner_id":1,"ts":"2025-10-19T00:29:00Z","count":3,"dims":{"country":"KZ"}}
+{"banner_id":"2","ts":"2025-10-19T00:29:00Z","kind":"impression"}
+{"banner_id":0}
+not json`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			agg := service.NewMockAggregatorPort(ctrl)
+			agg.EXPECT().Add(service.Event{BannerID: 1, TS: ts, Count: 3, Dims: "country=KZ"})
+			agg.EXPECT().Add(service.Event{BannerID: 2, TS: ts, Count: 1, Kind: service.KindImpression})
+
+			dims := service.NewDimensions(map[string]int{"country": 10})
+			srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, dims, VisitorConfig{}, nil, nil, nil, nil)
+			req := httptest.NewRequest(http.MethodPost, "/counter/batch", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			srv.httpSrv.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+			}
+			var resp entity.BatchResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Accepted != 2 || resp.Rejected != 2 || len(resp.Results) != 4 {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+			if !resp.Results[0].Accepted || !resp.Results[1].Accepted || resp.Results[2].Accepted || resp.Results[3].Accepted {
+				t.Fatalf("unexpected per-item results: %+v", resp.Results)
+			}
+		})
+	}
+}
+
+func TestHandleCounterBatch_CountLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// count сверх лимита, в том числе на границе int64, отклоняется и до агрегатора не доходит
+	agg := service.NewMockAggregatorPort(ctrl)
+	agg.EXPECT().Add(gomock.Any()).Times(2)
+	srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, service.NewDimensions(nil), VisitorConfig{}, nil, nil, nil, nil)
+	srv.UseMaxItemCount(10)
+	body := `[{"banner_id":1,"count":10},{"banner_id":1,"count":11},{"banner_id":1,"count":9223372036854775807},{"banner_id":1,"count":10}]`
+	req := httptest.NewRequest(http.MethodPost, "/counter/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, req)
+
+	var resp entity.BatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Accepted != 2 || resp.Rejected != 2 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	for _, i := range []int{1, 2} {
+		if resp.Results[i].Accepted || resp.Results[i].Error != "count too large" {
+			t.Fatalf("expected item %d rejected as too large, got %+v", i, resp.Results[i])
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package http_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+func (s *Server) handleCampaignList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := s.campaigns.List(r.Context())
+		if err != nil {
+			s.campaignError(w, err)
+			return
+		}
+		resp := entity.CampaignList{Campaigns: make([]entity.Campaign, 0, len(list))}
+		for _, c := range list {
+			resp.Campaigns = append(resp.Campaigns, campaignDTO(c))
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) handleCampaignGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseCampaignID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c, err := s.campaigns.Get(r.Context(), id)
+		if err != nil {
+			s.campaignError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, campaignDTO(c))
+	}
+}
+
+func (s *Server) handleCampaignCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req entity.CampaignRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		c, err := s.campaigns.Create(r.Context(), service.Campaign{ID: req.ID, Name: req.Name, Advertiser: req.Advertiser})
+		if err != nil {
+			s.campaignError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, campaignDTO(c))
+	}
+}
+
+func (s *Server) handleCampaignAddBanner() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseCampaignID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req entity.CampaignBannerRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var from time.Time
+		if req.From != "" {
+			if from, err = parseISO(req.From); err != nil {
+				http.Error(w, "invalid from", http.StatusBadRequest)
+				return
+			}
+		}
+		m, err := s.campaigns.AddBanner(r.Context(), id, req.BannerID, from)
+		if err != nil {
+			s.campaignError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, memberDTO(m))
+	}
+}
+
+// handleCampaignRemoveBanner завершает членство баннера в момент ?at= (по умолчанию — сейчас);
+// статистика кампании до этого момента продолжает учитывать баннер.
+func (s *Server) handleCampaignRemoveBanner() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseCampaignID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bannerID, err := parseBannerID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var at time.Time
+		if v := r.URL.Query().Get("at"); v != "" {
+			if at, err = parseISO(v); err != nil {
+				http.Error(w, "invalid at", http.StatusBadRequest)
+				return
+			}
+		}
+		m, err := s.campaigns.RemoveBanner(r.Context(), id, bannerID, at)
+		if err != nil {
+			s.campaignError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, memberDTO(m))
+	}
+}
+
+// handleCampaignStats — сумма рядов баннеров кампании (тело — как у /stats/{bannerID}).
+func (s *Server) handleCampaignStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseCampaignID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q, ok := decodeStatsQuery(w, r)
+		if !ok {
+			return
+		}
+		resp, err := s.campaigns.Query(r.Context(), id, q)
+		s.writeStats(w, r, resp, err)
+	}
+}
+
+func parseCampaignID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "campaignID"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid campaignID")
+	}
+	return id, nil
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (s *Server) campaignError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidCampaign):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrUnknownCampaign), errors.Is(err, service.ErrNotMember):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrCampaignExists), errors.Is(err, service.ErrAlreadyMember):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		s.log.Error("campaigns", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+func campaignDTO(c service.Campaign) entity.Campaign {
+	out := entity.Campaign{ID: c.ID, Name: c.Name, Advertiser: c.Advertiser, CreatedAt: c.CreatedAt}
+	for _, m := range c.Members {
+		out.Members = append(out.Members, memberDTO(m))
+	}
+	return out
+}
+
+func memberDTO(m service.Membership) entity.CampaignMember {
+	return entity.CampaignMember{BannerID: m.BannerID, From: m.From, To: m.To}
+}
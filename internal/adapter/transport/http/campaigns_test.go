@@ -0,0 +1,74 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestHandleCampaignStats(t *testing.T) {
+	from := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		queried bool
+		err     error
+		want    int
+	}{
+		{"ok", "/stats/campaign/7", `{"from":"2025-10-01T00:00:00Z","to":"2025-10-01T01:00:00Z","granularity":"hour"}`, true, nil, http.StatusOK},
+		{"unknown campaign", "/stats/campaign/7", `{"from":"2025-10-01T00:00:00Z","to":"2025-10-01T01:00:00Z"}`, true, service.ErrUnknownCampaign, http.StatusNotFound},
+		{"invalid id", "/stats/campaign/x", `{"from":"2025-10-01T00:00:00Z","to":"2025-10-01T01:00:00Z"}`, false, nil, http.StatusBadRequest},
+		{"invalid range", "/stats/campaign/7", `{"from":"2025-10-01T01:00:00Z","to":"2025-10-01T00:00:00Z"}`, false, nil, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			campaigns := service.NewMockCampaignPort(ctrl)
+			if tc.queried {
+				campaigns.EXPECT().Query(gomock.Any(), int64(7), gomock.Any()).
+					DoAndReturn(func(_ any, _ int64, q service.StatsQuery) (*entity.StatsResponse, error) {
+						if !q.From.Equal(from) || !q.To.Equal(from.Add(time.Hour)) {
+							t.Errorf("unexpected query %+v", q)
+						}
+						return &entity.StatsResponse{Stats: []entity.Point{{TS: from, V: 3}}}, tc.err
+					})
+			}
+			srv := NewServer(zap.NewNop(), ":0", nil, nil, nil, nil, VisitorConfig{}, nil, campaigns, nil, nil)
+			rec := httptest.NewRecorder()
+			srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body)))
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHandleCampaignAddBanner_AlreadyMember(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	campaigns := service.NewMockCampaignPort(ctrl)
+	campaigns.EXPECT().AddBanner(gomock.Any(), int64(7), int64(3), time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)).
+		Return(service.Membership{}, service.ErrAlreadyMember)
+
+	srv := NewServer(zap.NewNop(), ":0", nil, nil, nil, nil, VisitorConfig{}, nil, campaigns, nil, nil)
+	srv.UseAdminToken("secret")
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"banner_id":3,"from":"2025-10-01T00:00:00Z"}`)
+	req := httptest.NewRequest(http.MethodPost, "/campaigns/7/banners", body)
+	req.Header.Set("Authorization", "Bearer secret")
+	srv.httpSrv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,177 @@
+package http_server
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/parquet-go/parquet-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// exportFlushRows — через сколько строк CSV отправлять клиенту накопленный буфер.
+	exportFlushRows = 1000
+	// exportRowGroup — строк в группе Parquet (столько строк буферизуется в памяти).
+	exportRowGroup = 10000
+)
+
+// exportRow — строка выгрузки Parquet.
+type exportRow struct {
+	BannerID    int64             `parquet:"banner_id"`
+	TS          time.Time         `parquet:"ts,timestamp(millisecond)"`
+	Dims        map[string]string `parquet:"dims"`
+	Clicks      int64             `parquet:"clicks"`
+	Impressions int64             `parquet:"impressions"`
+}
+
+// handleExport — потоковая выгрузка строк всех баннеров за from/to с шагом granularity
+// (minute, hour или day; по умолчанию hour) в CSV или Parquet. Формат — параметр format
+// или заголовок Accept.
+func (s *Server) handleExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		var format string
+		switch v := params.Get("format"); v {
+		case "csv":
+			format = mimeCSV
+		case "parquet":
+			format = mimeParquet
+		case "":
+			var ok bool
+			if format, ok = negotiate(r, mimeCSV, mimeParquet); !ok {
+				http.Error(w, "not acceptable", http.StatusNotAcceptable)
+				return
+			}
+		default:
+			http.Error(w, "unknown format", http.StatusBadRequest)
+			return
+		}
+		q, err := parseExportQuery(params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ext := "csv"
+		if format == mimeParquet {
+			ext = "parquet"
+		}
+		w.Header().Set("Content-Type", format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="clicks-%s-%s.%s"`,
+			q.From.Format("20060102T1504Z"), q.To.Format("20060102T1504Z"), ext))
+		cw := &countingWriter{w: w}
+		if format == mimeParquet {
+			err = s.exportParquet(r, cw, q)
+		} else {
+			err = s.exportCSV(r, cw, q)
+		}
+		switch {
+		case err == nil:
+		case cw.n > 0:
+			// Заголовки уже отправлены: обрываем ответ, чтобы клиент не принял его за полный
+			s.log.Warn("export aborted", zap.Error(err))
+			panic(http.ErrAbortHandler)
+		case errors.Is(err, service.ErrRangeTooLarge), errors.Is(err, service.ErrUnknownGranularity):
+			w.Header().Del("Content-Disposition")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			s.log.Error("export", zap.Error(err))
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "internal", http.StatusInternalServerError)
+		}
+	}
+}
+
+func parseExportQuery(params url.Values) (service.ExportQuery, error) {
+	q := service.ExportQuery{Granularity: service.GranularityHour}
+	var err error
+	if q.From, err = parseISO(params.Get("from")); err != nil {
+		return q, errors.New("invalid from")
+	}
+	if q.To, err = parseISO(params.Get("to")); err != nil {
+		return q, errors.New("invalid to")
+	}
+	if !q.To.After(q.From) {
+		return q, errors.New("to must be after from")
+	}
+	if v := params.Get("granularity"); v != "" {
+		q.Granularity = service.Granularity(v)
+	}
+	return q, nil
+}
+
+// exportCSV пишет колонки banner_id, ts, по колонке на каждое настроенное измерение, clicks, impressions.
+func (s *Server) exportCSV(r *http.Request, w *countingWriter, q service.ExportQuery) error {
+	names := s.dims.Names()
+	out := csv.NewWriter(w)
+	rc := http.NewResponseController(w.w)
+	// Заголовок пишется вместе с первой строкой: до нее ошибка еще может стать статусом ответа
+	header := append(append([]string{"banner_id", "ts"}, names...), "clicks", "impressions")
+	rec := make([]string, 0, len(header))
+	var n int
+	err := s.stats.Export(r.Context(), q, func(row service.AggregateRow) error {
+		if n == 0 {
+			if err := out.Write(header); err != nil {
+				return err
+			}
+		}
+		dims := row.Dims.Map()
+		rec = append(rec[:0], strconv.FormatInt(row.BannerID, 10), row.TS.Format(time.RFC3339))
+		for _, name := range names {
+			rec = append(rec, dims[name])
+		}
+		rec = append(rec, strconv.FormatInt(row.Cnt, 10), strconv.FormatInt(row.Imps, 10))
+		if err := out.Write(rec); err != nil {
+			return err
+		}
+		if n++; n%exportFlushRows == 0 {
+			out.Flush()
+			if err := out.Error(); err != nil {
+				return err
+			}
+			_ = rc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		_ = out.Write(header)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// exportParquet пишет строки группами по exportRowGroup; измерения — колонка-карта dims.
+func (s *Server) exportParquet(r *http.Request, w *countingWriter, q service.ExportQuery) error {
+	pw := parquet.NewGenericWriter[exportRow](w, parquet.MaxRowsPerRowGroup(exportRowGroup))
+	buf := make([]exportRow, 1)
+	err := s.stats.Export(r.Context(), q, func(row service.AggregateRow) error {
+		buf[0] = exportRow{BannerID: row.BannerID, TS: row.TS, Dims: row.Dims.Map(), Clicks: row.Cnt, Impressions: row.Imps}
+		_, err := pw.Write(buf)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// countingWriter считает байты, уже отправленные клиенту.
+type countingWriter struct {
+	w http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
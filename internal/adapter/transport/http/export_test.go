@@ -0,0 +1,82 @@
+package http_server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"github.com/parquet-go/parquet-go"
+	"go.uber.org/zap"
+)
+
+func TestHandleExport(t *testing.T) {
+	from := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	rows := []service.AggregateRow{
+		{BannerID: 1, TS: from, Dims: service.MakeDims(map[string]string{"country": "KZ"}), Cnt: 3, Imps: 10},
+		{BannerID: 2, TS: from.Add(time.Hour), Cnt: 1},
+	}
+	export := func(_ context.Context, _ service.ExportQuery, fn func(service.AggregateRow) error) error {
+		for _, r := range rows {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	newServer := func(t *testing.T) *Server {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		stats := service.NewMockStatsPort(ctrl)
+		stats.EXPECT().Export(gomock.Any(), service.ExportQuery{From: from, To: to, Granularity: service.GranularityHour}, gomock.Any()).
+			DoAndReturn(export)
+		dims := service.NewDimensions(map[string]int{"country": 10, "device": 10})
+		return NewServer(zap.NewNop(), ":0", nil, stats, nil, dims, VisitorConfig{}, nil, nil, nil, nil)
+	}
+	const query = "/export?from=2025-10-01T00:00:00Z&to=2025-10-01T02:00:00Z"
+
+	t.Run("csv", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newServer(t).httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, query, nil))
+		want := "banner_id,ts,country,device,clicks,impressions\n" +
+			"1,2025-10-01T00:00:00Z,KZ,,3,10\n" +
+			"2,2025-10-01T01:00:00Z,,,1,0\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Fatalf("unexpected response %d:\n%s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("parquet", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, query, nil)
+		req.Header.Set("Accept", mimeParquet)
+		newServer(t).httpSrv.Handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != mimeParquet {
+			t.Fatalf("unexpected response %d: %v", rec.Code, rec.Header())
+		}
+		got, err := parquet.Read[exportRow](bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatalf("read parquet: %v", err)
+		}
+		if len(got) != 2 || got[0].BannerID != 1 || !got[0].TS.Equal(from) || got[0].Dims["country"] != "KZ" ||
+			got[0].Clicks != 3 || got[0].Impressions != 10 || got[1].BannerID != 2 {
+			t.Fatalf("unexpected rows: %+v", got)
+		}
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		srv := NewServer(zap.NewNop(), ":0", nil, nil, nil, nil, VisitorConfig{}, nil, nil, nil, nil)
+		for _, q := range []string{query + "&format=xml", "/export?from=2025-10-01T00:00:00Z"} {
+			rec := httptest.NewRecorder()
+			srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, q, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("%s: expected 400, got %d", q, rec.Code)
+			}
+		}
+	})
+}
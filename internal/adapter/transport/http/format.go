@@ -0,0 +1,236 @@
+package http_server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+)
+
+const (
+	mimeJSON    = "application/json"
+	mimeCSV     = "text/csv"
+	mimeNDJSON  = "application/x-ndjson"
+	mimeParquet = "application/vnd.apache.parquet"
+)
+
+// negotiate выбирает из offers тип с наибольшим q в заголовке Accept (при равных q —
+// первый из offers). Без Accept — offers[0]; false — ни один тип не допустим.
+func negotiate(r *http.Request, offers ...string) (string, bool) {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := acceptQ(accept, offer)
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// acceptQ — вес типа offer в заголовке Accept (0 — не допустим).
+func acceptQ(accept, offer string) float64 {
+	typ, _, _ := strings.Cut(offer, "/")
+	var q float64
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || (mt != offer && mt != "*/*" && mt != typ+"/*") {
+			continue
+		}
+		pq := 1.0
+		if v, ok := params["q"]; ok {
+			if pq, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if pq > q {
+			q = pq
+		}
+	}
+	return q
+}
+
+// flatPoint — точка ряда одной строкой CSV/NDJSON.
+type flatPoint struct {
+	BannerID *int64
+	Dims     map[string]string
+	entity.Point
+}
+
+// flatten разворачивает ответ статистики в строки: при group_by — точки групп,
+// иначе — итоговый ряд. Сводка и сравнение в строки не попадают.
+func flatten(resp any) (rows []flatPoint, dims []string, withBanner bool, ok bool) {
+	names := map[string]bool{}
+	add := func(id *int64, sr *entity.StatsResponse) {
+		if sr == nil {
+			return
+		}
+		if len(sr.Groups) == 0 {
+			for _, p := range sr.Stats {
+				rows = append(rows, flatPoint{BannerID: id, Point: p})
+			}
+			return
+		}
+		for _, g := range sr.Groups {
+			for n := range g.Dims {
+				names[n] = true
+			}
+			for _, p := range g.Stats {
+				rows = append(rows, flatPoint{BannerID: id, Dims: g.Dims, Point: p})
+			}
+		}
+	}
+	switch v := resp.(type) {
+	case *entity.StatsResponse:
+		add(nil, v)
+	case entity.MultiStatsResponse:
+		ids := make([]int64, 0, len(v.Banners))
+		for id := range v.Banners {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			add(&id, v.Banners[id])
+		}
+		withBanner = true
+	default:
+		return nil, nil, false, false
+	}
+	for n := range names {
+		dims = append(dims, n)
+	}
+	sort.Strings(dims)
+	return rows, dims, withBanner, true
+}
+
+// writeRows пишет resp построчно в format (CSV или NDJSON); false — у resp нет построчного вида.
+func writeRows(w http.ResponseWriter, format string, resp any) (bool, error) {
+	if top, ok := resp.(*entity.TopResponse); ok {
+		if format == mimeCSV {
+			return true, writeTopCSV(w, top)
+		}
+		return true, writeTopNDJSON(w, top)
+	}
+	rows, dims, withBanner, ok := flatten(resp)
+	if !ok {
+		return false, nil
+	}
+	if format == mimeCSV {
+		return true, writeCSV(w, rows, dims, withBanner)
+	}
+	return true, writeNDJSON(w, rows)
+}
+
+// writeCSV пишет строки с заголовком: [banner_id,] ts, [измерения,] clicks, impressions, ctr, uniques.
+// У пустых бакетов fill=null колонка clicks пуста.
+func writeCSV(w http.ResponseWriter, rows []flatPoint, dims []string, withBanner bool) error {
+	w.Header().Set("Content-Type", mimeCSV+"; charset=utf-8")
+	cw := csv.NewWriter(w)
+	var header []string
+	if withBanner {
+		header = append(header, "banner_id")
+	}
+	header = append(header, "ts")
+	header = append(header, dims...)
+	header = append(header, "clicks", "impressions", "ctr", "uniques")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	rec := make([]string, 0, len(header))
+	for _, r := range rows {
+		rec = rec[:0]
+		if withBanner {
+			rec = append(rec, strconv.FormatInt(*r.BannerID, 10))
+		}
+		rec = append(rec, r.TS.Format(time.RFC3339))
+		for _, d := range dims {
+			rec = append(rec, r.Dims[d])
+		}
+		if r.Null {
+			rec = append(rec, "", "", "", "")
+		} else {
+			rec = append(rec, strconv.FormatInt(r.V, 10), strconv.FormatInt(r.Impressions, 10), formatFloat(r.CTR), formatInt(r.Uniques))
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeNDJSON пишет по объекту JSON на точку; пустые бакеты fill=null — с "v": null.
+func writeNDJSON(w http.ResponseWriter, rows []flatPoint) error {
+	w.Header().Set("Content-Type", mimeNDJSON)
+	enc := json.NewEncoder(w)
+	for _, r := range rows {
+		line := struct {
+			BannerID    *int64            `json:"banner_id,omitempty"`
+			Dims        map[string]string `json:"dims,omitempty"`
+			TS          time.Time         `json:"ts"`
+			V           *int64            `json:"v"`
+			Impressions int64             `json:"impressions,omitempty"`
+			CTR         *float64          `json:"ctr,omitempty"`
+			Uniques     *int64            `json:"uniques,omitempty"`
+		}{BannerID: r.BannerID, Dims: r.Dims, TS: r.TS, Impressions: r.Impressions, CTR: r.CTR, Uniques: r.Uniques}
+		if !r.Null {
+			v := r.V
+			line.V = &v
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTopCSV пишет топ строками banner_id, clicks в порядке рейтинга.
+func writeTopCSV(w http.ResponseWriter, top *entity.TopResponse) error {
+	w.Header().Set("Content-Type", mimeCSV+"; charset=utf-8")
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"banner_id", "clicks"}); err != nil {
+		return err
+	}
+	for _, b := range top.Top {
+		if err := cw.Write([]string{strconv.FormatInt(b.BannerID, 10), strconv.FormatInt(b.Clicks, 10)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeTopNDJSON пишет по объекту JSON на баннер топа.
+func writeTopNDJSON(w http.ResponseWriter, top *entity.TopResponse) error {
+	w.Header().Set("Content-Type", mimeNDJSON)
+	enc := json.NewEncoder(w)
+	for _, b := range top.Top {
+		if err := enc.Encode(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'g', -1, 64)
+}
+
+func formatInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
@@ -0,0 +1,83 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{mimeJSON, mimeCSV, mimeNDJSON}
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", mimeJSON, true},
+		{"*/*", mimeJSON, true},
+		{"text/csv", mimeCSV, true},
+		{"text/*", mimeCSV, true},
+		{"application/json;q=0.5, application/x-ndjson", mimeNDJSON, true},
+		{"text/csv;q=0, */*;q=0.1", mimeJSON, true},
+		{"application/xml", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tc.accept)
+			got, ok := negotiate(r, offers...)
+			if ok != tc.ok || (ok && got != tc.want) {
+				t.Fatalf("expected %q/%v, got %q/%v", tc.want, tc.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestHandleMultiStats_Formats(t *testing.T) {
+	ts := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	ctr := 0.5
+	resp := map[int64]*entity.StatsResponse{
+		2: {Stats: []entity.Point{{TS: ts, Null: true}}},
+		1: {Stats: []entity.Point{{TS: ts, V: 3, Impressions: 6, CTR: &ctr}}},
+	}
+	tests := []struct {
+		accept string
+		status int
+		body   string
+	}{
+		{"text/csv", http.StatusOK, "banner_id,ts,clicks,impressions,ctr,uniques\n" +
+			"1,2025-10-01T00:00:00Z,3,6,0.5,\n" +
+			"2,2025-10-01T00:00:00Z,,,,\n"},
+		{"application/x-ndjson", http.StatusOK, `{"banner_id":1,"ts":"2025-10-01T00:00:00Z","v":3,"impressions":6,"ctr":0.5}` + "\n" +
+			`{"banner_id":2,"ts":"2025-10-01T00:00:00Z","v":null}` + "\n"},
+		{"application/xml", http.StatusNotAcceptable, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.accept, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			stats := service.NewMockStatsPort(ctrl)
+			stats.EXPECT().QueryMulti(gomock.Any(), gomock.Any(), []int64{1, 2}).Return(resp, nil).AnyTimes()
+			srv := NewServer(zap.NewNop(), ":0", nil, stats, nil, nil, VisitorConfig{}, nil, nil, nil, nil)
+			req := httptest.NewRequest(http.MethodPost, "/stats",
+				strings.NewReader(`{"banner_ids":[1,2],"from":"2025-10-01T00:00:00Z","to":"2025-10-01T00:01:00Z"}`))
+			req.Header.Set("Accept", tc.accept)
+			rec := httptest.NewRecorder()
+			srv.httpSrv.Handler.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, rec.Code, rec.Body)
+			}
+			if tc.status == http.StatusOK && rec.Body.String() != tc.body {
+				t.Fatalf("unexpected body:\n%s", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package http_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestHandleReady(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	health := service.NewMockHealthPort(ctrl)
+	health.EXPECT().Ready(gomock.Any()).Return(entity.Readiness{Status: service.HealthFail, Checks: []entity.HealthCheck{
+		{Name: "database", Status: service.HealthFail, Error: "connection refused"},
+	}}, false)
+	srv := NewServer(zap.NewNop(), ":0", nil, nil, nil, nil, VisitorConfig{}, nil, nil, health, nil)
+
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var resp entity.Readiness
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Status != service.HealthFail || len(resp.Checks) != 1 {
+		t.Fatalf("unexpected body %+v (%v)", resp, err)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 from /livez, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,70 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+type fakeMetrics struct {
+	routes []string
+	events []string
+}
+
+func (f *fakeMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+func (f *fakeMetrics) ObserveEvent(_ service.EventKind, result string) {
+	f.events = append(f.events, result)
+}
+func (f *fakeMetrics) ObserveHTTP(method, route string, status int, _ time.Duration) {
+	f.routes = append(f.routes, method+" "+route+" "+http.StatusText(status))
+}
+
+func TestServer_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	agg := service.NewMockAggregatorPort(ctrl)
+	agg.EXPECT().Add(gomock.Any())
+	m := &fakeMetrics{}
+	srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, nil, VisitorConfig{}, nil, nil, nil, m)
+	for _, path := range []string{"/counter/42", "/counter/x", "/nope", "/metrics"} {
+		srv.httpSrv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	wantRoutes := []string{"GET /counter/{bannerID} No Content", "GET /counter/{bannerID} Bad Request", "GET unmatched Not Found", "GET /metrics OK"}
+	if len(m.routes) != len(wantRoutes) {
+		t.Fatalf("expected %v, got %v", wantRoutes, m.routes)
+	}
+	for i := range wantRoutes {
+		if m.routes[i] != wantRoutes[i] {
+			t.Fatalf("expected %v, got %v", wantRoutes, m.routes)
+		}
+	}
+	if len(m.events) != 1 || m.events[0] != "accepted" {
+		t.Fatalf("unexpected events %v", m.events)
+	}
+}
+
+func TestHandleCounter_Overloaded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	agg := service.NewMockAggregatorPort(ctrl)
+	agg.EXPECT().Add(gomock.Any()).Return(service.ErrOverloaded)
+	m := &fakeMetrics{}
+	srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, nil, VisitorConfig{}, nil, nil, nil, m)
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/counter/42", nil))
+
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+	if len(m.events) != 1 || m.events[0] != "rejected" {
+		t.Fatalf("unexpected events %v", m.events)
+	}
+}
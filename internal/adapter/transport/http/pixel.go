@@ -0,0 +1,78 @@
+package http_server
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// transparentGIF — прозрачный GIF 1x1.
+var transparentGIF = []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+
+// maxBeaconBytes ограничивает тело запроса sendBeacon.
+const maxBeaconBytes = 4 << 10
+
+// handlePixel учитывает событие как handleCounter (вид — параметр kind)
+// и отдает прозрачный GIF, запрещая его кэширование. Отклоненное событие тоже
+// получает GIF (со статусом ошибки), чтобы <img> на странице не показывал
+// значок битой картинки.
+func (s *Server) handlePixel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		kind, err := parseKind(q.Get("kind"))
+		if err == nil {
+			err = s.count(r, kind, q)
+		}
+		status := http.StatusOK
+		if err != nil {
+			status = trackStatus(err)
+			s.log.Debug("pixel event rejected", zap.String("path", r.URL.Path), zap.Int("status", status), zap.Error(err))
+		}
+		h := w.Header()
+		h.Set("Content-Type", "image/gif")
+		h.Set("Cache-Control", "no-cache, no-store, must-revalidate, max-age=0")
+		h.Set("Pragma", "no-cache")
+		h.Set("Expires", "0")
+		w.WriteHeader(status)
+		_, _ = w.Write(transparentGIF)
+	}
+}
+
+// handleBeacon принимает navigator.sendBeacon: тело (обычно text/plain) содержит
+// параметры в форме query-строки ("ts=...&country=KZ&kind=impression"),
+// они дополняют и переопределяют параметры адреса.
+func (s *Server) handleBeacon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBeaconBytes+1))
+		if err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxBeaconBytes {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		params := r.URL.Query()
+		form, err := url.ParseQuery(strings.TrimSpace(string(body)))
+		if err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		for k, v := range form {
+			params[k] = v
+		}
+		kind, err := parseKind(params.Get("kind"))
+		if err == nil {
+			err = s.count(r, kind, params)
+		}
+		if err != nil {
+			trackError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,100 @@
+package http_server
+
+import (
+	"bytes"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestHandlePixel(t *testing.T) {
+	for _, path := range []string{"/pixel/5", "/pixel/5.gif"} {
+		t.Run(path, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			agg := service.NewMockAggregatorPort(ctrl)
+			agg.EXPECT().Add(gomock.Any()).Do(func(ev service.Event) {
+				if ev.BannerID != 5 || ev.Kind != service.KindImpression || ev.Dims != "country=KZ" {
+					t.Fatalf("unexpected event %+v", ev)
+				}
+			})
+			dims := service.NewDimensions(map[string]int{"country": 10})
+			srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, dims, VisitorConfig{}, nil, nil, nil, nil)
+
+			rec := httptest.NewRecorder()
+			srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?kind=impression&country=KZ", nil))
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/gif" {
+				t.Fatalf("expected gif, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+			}
+			if !strings.Contains(rec.Header().Get("Cache-Control"), "no-store") {
+				t.Fatalf("expected no-store, got %q", rec.Header().Get("Cache-Control"))
+			}
+			img, err := gif.Decode(bytes.NewReader(rec.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("decode gif: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != 1 || b.Dy() != 1 {
+				t.Fatalf("expected 1x1 image, got %v", b)
+			}
+		})
+	}
+}
+
+func TestHandlePixel_RejectedStillGIF(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	agg := service.NewMockAggregatorPort(ctrl)
+	agg.EXPECT().Add(gomock.Any()).Return(service.ErrOverloaded)
+	srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, nil, VisitorConfig{}, nil, nil, nil, nil)
+
+	for path, want := range map[string]int{
+		"/pixel/5":              http.StatusServiceUnavailable,
+		"/pixel/5?kind=unknown": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want || rec.Header().Get("Content-Type") != "image/gif" || !bytes.Equal(rec.Body.Bytes(), transparentGIF) {
+			t.Fatalf("%s: expected gif with %d, got %d %q", path, want, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rec.Header().Get("Cache-Control"), "no-store") {
+			t.Fatalf("%s: expected no-store, got %q", path, rec.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestHandleBeacon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	agg := service.NewMockAggregatorPort(ctrl)
+	agg.EXPECT().Add(gomock.Any()).Do(func(ev service.Event) {
+		if ev.BannerID != 5 || ev.Kind != service.KindClick || ev.Dims != "country=DE" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	})
+	dims := service.NewDimensions(map[string]int{"country": 10})
+	srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, dims, VisitorConfig{}, nil, nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/pixel/5?country=KZ", strings.NewReader("country=DE\n"))
+	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/pixel/5", strings.NewReader(strings.Repeat("x", maxBeaconBytes+1)))
+	rec = httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,37 @@
+package http_server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// handleRedirect учитывает клик и отправляет на адрес баннера из реестра,
+// подставив макросы и utm-параметры запроса.
+func (s *Server) handleRedirect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseBannerID(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bn, ok := s.banners.Get(id)
+		if !ok || bn.Archived() || bn.TargetURL == "" {
+			http.Error(w, service.ErrUnknownBanner.Error(), http.StatusNotFound)
+			return
+		}
+		now := time.Now()
+		dims := s.captureDims(r, r.URL.Query())
+		_ = s.track(service.Event{BannerID: id, TS: now, Count: 1, Dims: dims, Visitor: s.visitor(r)}, false)
+
+		vars := dims.Map()
+		vars["banner_id"] = strconv.FormatInt(id, 10)
+		vars["ts"] = strconv.FormatInt(now.Unix(), 10)
+		vars["request_id"] = middleware.GetReqID(r.Context())
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, service.ExpandTarget(bn.TargetURL, vars, r.URL.Query()), http.StatusFound)
+	}
+}
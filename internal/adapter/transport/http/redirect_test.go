@@ -0,0 +1,44 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestHandleRedirect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	agg := service.NewMockAggregatorPort(ctrl)
+	banners := service.NewMockBannerRegistryPort(ctrl)
+	banners.EXPECT().Get(int64(1)).Return(service.Banner{ID: 1, TargetURL: "https://ads.example/land?b={banner_id}"}, true)
+	banners.EXPECT().Get(int64(2)).Return(service.Banner{}, false)
+	banners.EXPECT().Resolve(int64(1)).Return(int64(1), nil)
+	agg.EXPECT().Add(gomock.Any()).Do(func(ev service.Event) {
+		if ev.BannerID != 1 || ev.Count != 1 || ev.Kind != service.KindClick {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	})
+
+	srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, service.NewDimensions(nil), VisitorConfig{}, banners, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/1?utm_source=mail", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected 302, got %d", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "https://ads.example/land?b=1&utm_source=mail" {
+		t.Fatalf("unexpected location %q", loc)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/2", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown banner, got %d", rec.Code)
+	}
+}
@@ -2,44 +2,128 @@ package http_server
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dayanaadylkhanova/click-counter/internal/entity"
 	"github.com/dayanaadylkhanova/click-counter/internal/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 type Server struct {
-	log     *zap.Logger
-	addr    string
-	agg     service.AggregatorPort
-	stats   service.StatsReaderPort
-	maxDays int
-	httpSrv *http.Server
+	log       *zap.Logger
+	addr      string
+	agg       service.AggregatorPort
+	stats     service.StatsPort
+	window    *service.EventWindow
+	dims      *service.Dimensions
+	visitors  VisitorConfig
+	banners   service.BannerRegistryPort
+	campaigns service.CampaignPort
+	health    service.HealthPort
+	metrics   Metrics
+	// adminToken — bearer-токен для изменения реестров баннеров и кампаний;
+	// пусто — изменение через HTTP запрещено.
+	adminToken string
+	// maxItemCount — наибольший count элемента POST /counter/batch.
+	maxItemCount int64
+	httpSrv      *http.Server
 }
 
-func NewServer(log *zap.Logger, addr string, agg service.AggregatorPort, stats service.StatsReaderPort, maxDays int) *Server {
-	s := &Server{log: log, addr: addr, agg: agg, stats: stats, maxDays: maxDays}
+// Metrics — метрики приема событий и HTTP-запросов; сам отдает /metrics.
+type Metrics interface {
+	http.Handler
+	// ObserveEvent: result — accepted, diverted или rejected.
+	ObserveEvent(kind service.EventKind, result string)
+	ObserveHTTP(method, route string, status int, took time.Duration)
+}
+
+// NewServer: health может быть nil — тогда /readyz всегда готов;
+// metrics может быть nil — тогда /metrics не отдается.
+func NewServer(log *zap.Logger, addr string, agg service.AggregatorPort, stats service.StatsPort, window *service.EventWindow, dims *service.Dimensions, visitors VisitorConfig, banners service.BannerRegistryPort, campaigns service.CampaignPort, health service.HealthPort, metrics Metrics) *Server {
+	s := &Server{log: log, addr: addr, agg: agg, stats: stats, window: window, dims: dims, visitors: visitors, banners: banners, campaigns: campaigns, health: health, metrics: metrics, maxItemCount: defaultMaxItemCount}
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
+	r.Use(traceHTTP)
 	r.Use(middleware.Recoverer)
 	r.Use(zapLogger(log))
+	if metrics != nil {
+		r.Use(observeHTTP(metrics))
+		r.Method(http.MethodGet, "/metrics", metrics)
+	}
 
-	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
-	r.Get("/counter/{bannerID}", s.handleCounter())
+	// /livez — процесс жив; /healthz — прежнее имя той же проверки
+	r.Get("/livez", handleLive)
+	r.Get("/healthz", handleLive)
+	r.Get("/readyz", s.handleReady())
+	r.Get("/counter/{bannerID}", s.handleCounter(service.KindClick))
+	r.Get("/impression/{bannerID}", s.handleCounter(service.KindImpression))
+	r.Post("/counter/batch", s.handleCounterBatch())
+	r.Get("/r/{bannerID}", s.handleRedirect())
+	r.Get("/pixel/{bannerID}", s.handlePixel())
+	r.Get("/pixel/{bannerID}.gif", s.handlePixel())
+	r.Post("/pixel/{bannerID}", s.handleBeacon())
+	r.Route("/banners", func(r chi.Router) {
+		r.Get("/", s.handleBannerList())
+		r.Get("/{bannerID}", s.handleBannerGet())
+		r.With(s.requireAdmin).Post("/", s.handleBannerCreate())
+		r.With(s.requireAdmin).Put("/{bannerID}", s.handleBannerUpdate())
+		r.With(s.requireAdmin).Post("/{bannerID}/archive", s.handleBannerArchive())
+	})
+	r.Route("/campaigns", func(r chi.Router) {
+		r.Get("/", s.handleCampaignList())
+		r.Get("/{campaignID}", s.handleCampaignGet())
+		r.With(s.requireAdmin).Post("/", s.handleCampaignCreate())
+		r.With(s.requireAdmin).Post("/{campaignID}/banners", s.handleCampaignAddBanner())
+		r.With(s.requireAdmin).Delete("/{campaignID}/banners/{bannerID}", s.handleCampaignRemoveBanner())
+	})
+	r.Post("/stats", s.handleMultiStats())
+	r.Get("/top", s.handleTop())
+	r.Get("/export", s.handleExport())
 	r.Post("/stats/{bannerID}", s.handleStats())
+	r.Post("/stats/campaign/{campaignID}", s.handleCampaignStats())
 
 	s.httpSrv = &http.Server{Addr: addr, Handler: r}
 	return s
 }
 
+// UseAdminToken разрешает изменение баннеров и кампаний запросам
+// с заголовком "Authorization: Bearer <token>". Вызывается до Start.
+func (s *Server) UseAdminToken(token string) { s.adminToken = token }
+
+// requireAdmin пропускает только запросы с админским токеном: без него любой клиент
+// трекера мог бы подменить адрес перехода баннера или архивировать его.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			http.Error(w, "admin api disabled", http.StatusForbidden)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) Start() error {
 	s.log.Info("http listen", zap.String("addr", s.addr))
 	return s.httpSrv.ListenAndServe()
@@ -55,82 +139,355 @@ func zapLogger(log *zap.Logger) func(http.Handler) http.Handler {
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 			start := time.Now()
 			next.ServeHTTP(ww, r)
-			log.Info("http",
+			fields := []zap.Field{
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
 				zap.Int("status", ww.Status()),
 				zap.Int("bytes", ww.BytesWritten()),
 				zap.String("request_id", middleware.GetReqID(r.Context())),
 				zap.Duration("latency", time.Since(start)),
-			)
+			}
+			// trace_id связывает строку лога с трассой запроса (при включенной трассировке)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				fields = append(fields, zap.Stringer("trace_id", sc.TraceID()))
+			}
+			log.Info("http", fields...)
+		})
+	}
+}
+
+// traceHTTP открывает серверный спан запроса, продолжая трассу из заголовков W3C
+// traceparent/tracestate. Имя спана — метод и шаблон маршрута chi.
+func traceHTTP(next http.Handler) http.Handler {
+	tracer := otel.Tracer("github.com/dayanaadylkhanova/click-counter/internal/adapter/transport/http")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+			attribute.String("request_id", middleware.GetReqID(ctx)),
+		))
+		defer span.End()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if route := chi.RouteContext(ctx).RoutePattern(); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// observeHTTP пишет длительность запроса с шаблоном маршрута chi (не сырым путем,
+// чтобы ID в пути не раздували число рядов метрики).
+func observeHTTP(m Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+			next.ServeHTTP(ww, r)
+			route := chi.RouteContext(r.Context()).RoutePattern()
+			if route == "" {
+				route = "unmatched"
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.ObserveHTTP(r.Method, route, status, time.Since(start))
 		})
 	}
 }
 
-func (s *Server) handleCounter() http.HandlerFunc {
+func handleLive(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+// handleReady — готовность принимать трафик: 200 или 503 с результатами проверок.
+func (s *Server) handleReady() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := entity.Readiness{Status: service.HealthOK, Checks: []entity.HealthCheck{}}, true
+		if s.health != nil {
+			resp, ok = s.health.Ready(r.Context())
+		}
+		w.Header().Set("Content-Type", mimeJSON)
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// handleCounter учитывает одно событие вида kind (клик или показ).
+func (s *Server) handleCounter(kind service.EventKind) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id, err := parseBannerID(r)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := s.count(r, kind, r.URL.Query()); err != nil {
+			trackError(w, err)
 			return
 		}
-		s.agg.Inc(id, time.Now())
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// count учитывает одно событие баннера из пути запроса; время события (ts)
+// и значения измерений берутся из params.
+func (s *Server) count(r *http.Request, kind service.EventKind, params url.Values) error {
+	id, err := parseBannerID(r)
+	if err != nil {
+		return err
+	}
+	ev := service.Event{BannerID: id, TS: time.Now(), Count: 1, Dims: s.captureDims(r, params), Visitor: s.visitor(r), Kind: kind}
+	tsStr := params.Get("ts")
+	if tsStr != "" {
+		if ev.TS, err = parseEventTime(tsStr); err != nil {
+			return errors.New("invalid ts")
+		}
+	}
+	return s.track(ev, tsStr != "")
+}
+
+var errOutOfWindow = errors.New("ts out of allowed window")
+
+// track учитывает событие и его итог в метриках.
+func (s *Server) track(ev service.Event, clientTS bool) error {
+	result, err := s.admit(ev, clientTS)
+	if s.metrics != nil {
+		s.metrics.ObserveEvent(ev.Kind, result)
+	}
+	return err
+}
+
+// admit учитывает событие; баннер проверяется по реестру, клиентское время — EventWindow.
+// Возвращает итог для метрик: accepted, diverted или rejected.
+func (s *Server) admit(ev service.Event, clientTS bool) (string, error) {
+	if s.banners != nil {
+		id, err := s.banners.Resolve(ev.BannerID)
+		if err != nil {
+			return "rejected", err
+		}
+		ev.BannerID = id
+	}
+	if clientTS {
+		ts, verdict := s.window.Admit(ev.TS, time.Now())
+		switch verdict {
+		case service.Rejected:
+			return "rejected", errOutOfWindow
+		case service.Diverted:
+			return "diverted", nil
+		}
+		ev.TS = ts
+	}
+	if err := s.agg.Add(ev); err != nil {
+		return "rejected", err
+	}
+	return "accepted", nil
+}
+
+// trackStatus — HTTP-статус ошибки count/track.
+func trackStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUnknownBanner), errors.Is(err, service.ErrArchivedBanner):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrOverloaded):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// trackError отвечает статусом ошибки count/track; при перегрузке агрегатора
+// клиенту предлагается повторить позже.
+func trackError(w http.ResponseWriter, err error) {
+	status := trackStatus(err)
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// captureDims берет значения измерений из параметра с именем измерения
+// или из заголовка X-Click-<Name>.
+func (s *Server) captureDims(r *http.Request, params url.Values) service.Dims {
+	return s.dims.Capture(func(name string) string {
+		if v := params.Get(name); v != "" {
+			return v
+		}
+		return r.Header.Get("X-Click-" + name)
+	})
+}
+
 func (s *Server) handleStats() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id, err := parseBannerID(r)
+		id, err := parseStatsBannerID(r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		var req entity.StatsRequest
-		dec := json.NewDecoder(r.Body)
-		dec.DisallowUnknownFields()
-		if err := dec.Decode(&req); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+		q, ok := decodeStatsQuery(w, r)
+		if !ok {
 			return
 		}
+		q.BannerID = id
+		resp, err := s.stats.Query(r.Context(), q)
+		s.writeStats(w, r, resp, err)
+	}
+}
 
-		from, err := parseISO(req.From)
-		if err != nil {
-			http.Error(w, "invalid from", http.StatusBadRequest)
+// handleMultiStats — ряды нескольких баннеров одним запросом.
+func (s *Server) handleMultiStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req entity.MultiStatsRequest
+		if !decodeJSON(w, r, &req) {
 			return
 		}
-		to, err := parseISO(req.To)
-		if err != nil {
-			http.Error(w, "invalid to", http.StatusBadRequest)
+		if len(req.BannerIDs) == 0 {
+			http.Error(w, "banner_ids is required", http.StatusBadRequest)
 			return
 		}
-		if !to.After(from) {
-			http.Error(w, "to must be after from", http.StatusBadRequest)
+		for _, id := range req.BannerIDs {
+			if id <= 0 && id != service.QuarantineBannerID {
+				http.Error(w, "invalid bannerID", http.StatusBadRequest)
+				return
+			}
+		}
+		q, ok := statsQuery(w, req.StatsRequest)
+		if !ok {
 			return
 		}
+		res, err := s.stats.QueryMulti(r.Context(), q, req.BannerIDs)
+		s.writeStats(w, r, entity.MultiStatsResponse{Banners: res}, err)
+	}
+}
 
-		if s.maxDays > 0 && to.Sub(from) > (time.Hour*24*time.Duration(s.maxDays)) {
-			http.Error(w, "range too large", http.StatusBadRequest)
-			return
+// decodeStatsQuery разбирает тело запроса статистики (entity.StatsRequest);
+// при ошибке отвечает 400 и возвращает false.
+func decodeStatsQuery(w http.ResponseWriter, r *http.Request) (service.StatsQuery, bool) {
+	var req entity.StatsRequest
+	if !decodeJSON(w, r, &req) {
+		return service.StatsQuery{}, false
+	}
+	return statsQuery(w, req)
+}
+
+// statsQuery проверяет параметры запроса статистики; при ошибке отвечает 400.
+func statsQuery(w http.ResponseWriter, req entity.StatsRequest) (service.StatsQuery, bool) {
+	loc := time.UTC
+	if req.TZ != "" {
+		var err error
+		if loc, err = time.LoadLocation(req.TZ); err != nil {
+			http.Error(w, "invalid tz", http.StatusBadRequest)
+			return service.StatsQuery{}, false
+		}
+	}
+	from, err := parseISOIn(req.From, loc)
+	if err != nil {
+		http.Error(w, "invalid from", http.StatusBadRequest)
+		return service.StatsQuery{}, false
+	}
+	to, err := parseISOIn(req.To, loc)
+	if err != nil {
+		http.Error(w, "invalid to", http.StatusBadRequest)
+		return service.StatsQuery{}, false
+	}
+	if !to.After(from) {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return service.StatsQuery{}, false
+	}
+	var g service.Granularity
+	if req.Granularity != "" {
+		if g, err = service.ParseGranularity(req.Granularity); err != nil {
+			http.Error(w, "invalid granularity", http.StatusBadRequest)
+			return service.StatsQuery{}, false
 		}
+	}
 
-		from = from.Truncate(time.Minute).UTC()
-		to = to.Truncate(time.Minute).UTC()
+	fill, err := service.ParseFill(req.Fill)
+	if err != nil {
+		http.Error(w, "invalid fill", http.StatusBadRequest)
+		return service.StatsQuery{}, false
+	}
 
-		pts, err := s.stats.QueryRange(r.Context(), id, from, to)
-		if err != nil {
-			s.log.Error("query", zap.Error(err))
-			http.Error(w, "internal error", http.StatusInternalServerError)
+	cmp, err := service.ParseCompare(req.Compare)
+	if err != nil {
+		http.Error(w, "invalid compare", http.StatusBadRequest)
+		return service.StatsQuery{}, false
+	}
+
+	return service.StatsQuery{
+		From:           from,
+		To:             to,
+		Granularity:    g,
+		Fill:           fill,
+		IncludePending: req.IncludePending,
+		Filter:         service.MakeDims(req.Filter),
+		GroupBy:        req.GroupBy,
+		Uniques:        req.Uniques,
+		Summary:        req.Summary,
+		Compare:        cmp,
+		Loc:            loc,
+	}, true
+}
+
+// writeStats отвечает результатом запроса статистики или статусом ошибки.
+// Формат (JSON, CSV или NDJSON) выбирается по заголовку Accept.
+func (s *Server) writeStats(w http.ResponseWriter, r *http.Request, resp any, err error) {
+	format, ok := negotiate(r, mimeJSON, mimeCSV, mimeNDJSON)
+	if !ok {
+		http.Error(w, "not acceptable", http.StatusNotAcceptable)
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrRangeTooLarge):
+		http.Error(w, "range too large", http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrTooManyBanners):
+		http.Error(w, "too many banners", http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrUnknownDimension):
+		http.Error(w, "unknown dimension", http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrUnknownCampaign):
+		http.Error(w, "unknown campaign", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.Error("query", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if format != mimeJSON {
+		ok, err := writeRows(w, format, resp)
+		if !ok {
+			http.Error(w, "not acceptable", http.StatusNotAcceptable)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(entity.StatsResponse{Stats: pts})
+		if err != nil {
+			s.log.Warn("stats write", zap.Error(err))
+		}
+		return
 	}
+	w.Header().Set("Content-Type", mimeJSON)
+	_ = json.NewEncoder(w).Encode(resp)
 }
 
 func parseBannerID(r *http.Request) (int64, error) {
-	idStr := chi.URLParam(r, "bannerID")
+	return validateBannerID(chi.URLParam(r, "bannerID"))
+}
+
+// parseStatsBannerID дополнительно принимает карантинный баннер (BANNER_UNKNOWN_POLICY=quarantine).
+func parseStatsBannerID(r *http.Request) (int64, error) {
+	if idStr := chi.URLParam(r, "bannerID"); idStr == strconv.FormatInt(service.QuarantineBannerID, 10) {
+		return service.QuarantineBannerID, nil
+	}
+	return parseBannerID(r)
+}
+
+func validateBannerID(idStr string) (int64, error) {
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil || id <= 0 {
 		return 0, errors.New("invalid bannerID")
@@ -138,15 +495,41 @@ func parseBannerID(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// parseKind разбирает вид события: click (по умолчанию) или impression.
+func parseKind(s string) (service.EventKind, error) {
+	switch s {
+	case "", "click":
+		return service.KindClick, nil
+	case "impression":
+		return service.KindImpression, nil
+	default:
+		return 0, errors.New("invalid kind")
+	}
+}
+
+// parseEventTime принимает RFC3339 или unix-время в секундах.
+func parseEventTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return parseISO(s)
+}
+
 func parseISO(s string) (time.Time, error) {
+	t, err := parseISOIn(s, time.UTC)
+	return t.UTC(), err
+}
+
+// parseISOIn принимает RFC3339 или местное время loc без смещения ("2006-01-02T15:04:05").
+func parseISOIn(s string, loc *time.Location) (time.Time, error) {
 	if s == "" {
 		return time.Time{}, errors.New("empty")
 	}
 	if t, err := time.Parse(time.RFC3339, s); err == nil {
-		return t.UTC(), nil
+		return t.In(loc), nil
 	}
-	if t, err := time.Parse("2006-01-02T15:04:05", s); err == nil {
-		return t.UTC(), nil
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", s, loc); err == nil {
+		return t, nil
 	}
 	return time.Time{}, errors.New("bad time")
 }
@@ -0,0 +1,62 @@
+package http_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestHandleMultiStats(t *testing.T) {
+	const rng = `"from":"2025-10-01T00:00:00Z","to":"2025-10-01T01:00:00Z"`
+	tests := []struct {
+		name    string
+		body    string
+		queried bool
+		err     error
+		want    int
+	}{
+		{"ok", `{"banner_ids":[1,2],` + rng + `}`, true, nil, http.StatusOK},
+		{"too many banners", `{"banner_ids":[1,2],` + rng + `}`, true, service.ErrTooManyBanners, http.StatusBadRequest},
+		{"no banners", `{` + rng + `}`, false, nil, http.StatusBadRequest},
+		{"invalid banner", `{"banner_ids":[1,-2],` + rng + `}`, false, nil, http.StatusBadRequest},
+		{"invalid range", `{"banner_ids":[1],"from":"2025-10-01T01:00:00Z","to":"2025-10-01T00:00:00Z"}`, false, nil, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ts := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+			stats := service.NewMockStatsPort(ctrl)
+			if tc.queried {
+				stats.EXPECT().QueryMulti(gomock.Any(), gomock.Any(), []int64{1, 2}).Return(map[int64]*entity.StatsResponse{
+					1: {Stats: []entity.Point{{TS: ts, V: 3}}},
+					2: {Stats: []entity.Point{}},
+				}, tc.err)
+			}
+			srv := NewServer(zap.NewNop(), ":0", nil, stats, nil, nil, VisitorConfig{}, nil, nil, nil, nil)
+			rec := httptest.NewRecorder()
+			srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stats", strings.NewReader(tc.body)))
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, rec.Body)
+			}
+			if tc.want == http.StatusOK {
+				var resp entity.MultiStatsResponse
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if len(resp.Banners) != 2 || resp.Banners[1].Stats[0].V != 3 {
+					t.Fatalf("unexpected response: %+v", resp)
+				}
+			}
+		})
+	}
+}
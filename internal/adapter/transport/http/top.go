@@ -0,0 +1,67 @@
+package http_server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+)
+
+// defaultTopLimit — N в /top без параметра limit.
+const defaultTopLimit = 10
+
+// handleTop — N баннеров с наибольшим числом кликов. Диапазон — from/to или last
+// (длительность до to); без to — до конца текущей минуты, включая несохраненные клики.
+func (s *Server) handleTop() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseTopQuery(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := s.stats.Top(r.Context(), q)
+		if errors.Is(err, service.ErrInvalidTopLimit) {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		s.writeStats(w, r, resp, err)
+	}
+}
+
+func parseTopQuery(r *http.Request, now time.Time) (service.TopQuery, error) {
+	params := r.URL.Query()
+	q := service.TopQuery{To: now.UTC().Truncate(time.Minute).Add(time.Minute), Limit: defaultTopLimit}
+	var err error
+	if v := params.Get("to"); v != "" {
+		if q.To, err = parseISO(v); err != nil {
+			return q, errors.New("invalid to")
+		}
+	}
+	switch from, last := params.Get("from"), params.Get("last"); {
+	case from != "" && last != "":
+		return q, errors.New("from and last are mutually exclusive")
+	case from != "":
+		if q.From, err = parseISO(from); err != nil {
+			return q, errors.New("invalid from")
+		}
+	case last != "":
+		d, err := time.ParseDuration(last)
+		if err != nil || d <= 0 {
+			return q, errors.New("invalid last")
+		}
+		q.From = q.To.Add(-d)
+	default:
+		return q, errors.New("from or last is required")
+	}
+	if !q.To.After(q.From) {
+		return q, errors.New("to must be after from")
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 || q.Limit > service.MaxTopLimit {
+			return q, errors.New("invalid limit")
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,90 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestParseTopQuery(t *testing.T) {
+	now := time.Date(2025, 10, 19, 12, 30, 15, 0, time.UTC)
+	end := time.Date(2025, 10, 19, 12, 31, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		query    string
+		from, to time.Time
+		limit    int
+		ok       bool
+	}{
+		{"last hour up to current minute", "last=1h", end.Add(-time.Hour), end, 10, true},
+		{"explicit range and limit", "from=2025-10-18T00:00:00Z&to=2025-10-19T00:00:00Z&limit=50",
+			time.Date(2025, 10, 18, 0, 0, 0, 0, time.UTC), time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC), 50, true},
+		{"no range", "", time.Time{}, time.Time{}, 0, false},
+		{"from and last", "from=2025-10-18T00:00:00Z&last=1h", time.Time{}, time.Time{}, 0, false},
+		{"limit too large", "last=1h&limit=100000", time.Time{}, time.Time{}, 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := parseTopQuery(httptest.NewRequest("GET", "/top?"+tc.query, nil), now)
+			if !tc.ok {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", q)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !q.From.Equal(tc.from) || !q.To.Equal(tc.to) || q.Limit != tc.limit {
+				t.Fatalf("unexpected query %+v", q)
+			}
+		})
+	}
+}
+
+func TestHandleTop_Formats(t *testing.T) {
+	resp := &entity.TopResponse{Top: []entity.TopBanner{{BannerID: 7, Clicks: 120}, {BannerID: 3, Clicks: 95}}}
+	tests := []struct {
+		accept string
+		status int
+		ctype  string
+		body   string
+	}{
+		{"text/csv", http.StatusOK, "text/csv; charset=utf-8", "banner_id,clicks\n7,120\n3,95\n"},
+		{"application/x-ndjson", http.StatusOK, "application/x-ndjson",
+			`{"banner_id":7,"clicks":120}` + "\n" + `{"banner_id":3,"clicks":95}` + "\n"},
+		{"application/xml", http.StatusNotAcceptable, "", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.accept, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			stats := service.NewMockStatsPort(ctrl)
+			stats.EXPECT().Top(gomock.Any(), gomock.Any()).Return(resp, nil).AnyTimes()
+			srv := NewServer(zap.NewNop(), ":0", nil, stats, nil, nil, VisitorConfig{}, nil, nil, nil, nil)
+			req := httptest.NewRequest(http.MethodGet, "/top?last=1h", nil)
+			req.Header.Set("Accept", tc.accept)
+			rec := httptest.NewRecorder()
+			srv.httpSrv.Handler.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, rec.Code, rec.Body)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != tc.ctype {
+				t.Fatalf("expected content type %q, got %q", tc.ctype, got)
+			}
+			if rec.Body.String() != tc.body {
+				t.Fatalf("unexpected body:\n%s", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+	"github.com/golang/mock/gomock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+func TestTraceHTTP_ContinuesW3CTrace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	agg := service.NewMockAggregatorPort(ctrl)
+	agg.EXPECT().Add(gomock.Any())
+	srv := NewServer(zap.NewNop(), ":0", agg, nil, nil, nil, VisitorConfig{}, nil, nil, nil, nil)
+	req := httptest.NewRequest(http.MethodGet, "/counter/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	srv.httpSrv.Handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	sp := spans[0]
+	if sp.Name() != "GET /counter/{bannerID}" {
+		t.Fatalf("unexpected span name %q", sp.Name())
+	}
+	if sp.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sp.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("trace context not continued: %v parent %v", sp.SpanContext().TraceID(), sp.Parent().SpanID())
+	}
+}
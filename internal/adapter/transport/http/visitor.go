@@ -0,0 +1,43 @@
+package http_server
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+)
+
+// VisitorConfig — откуда брать идентификатор посетителя для подсчета уникальных.
+// Источники проверяются по порядку: заголовок, cookie, хэш IP и User-Agent.
+type VisitorConfig struct {
+	Header   string // пусто — не читать
+	Cookie   string // пусто — не читать
+	HashIPUA bool   // запасной вариант: хэш адреса клиента и User-Agent
+}
+
+// visitor возвращает хэш идентификатора посетителя или 0, если его нет.
+func (s *Server) visitor(r *http.Request) uint64 {
+	if s.visitors.Header != "" {
+		if v := r.Header.Get(s.visitors.Header); v != "" {
+			return service.VisitorHash(v)
+		}
+	}
+	if s.visitors.Cookie != "" {
+		if c, err := r.Cookie(s.visitors.Cookie); err == nil && c.Value != "" {
+			return service.VisitorHash(c.Value)
+		}
+	}
+	if s.visitors.HashIPUA {
+		// RemoteAddr уже заменен middleware.RealIP; порт отбрасывается, чтобы
+		// соединения одного клиента давали один хэш.
+		return service.VisitorHash(hostOf(r.RemoteAddr) + "\x00" + r.UserAgent())
+	}
+	return 0
+}
+
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,44 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/service"
+)
+
+func TestServer_Visitor(t *testing.T) {
+	cfg := VisitorConfig{Header: "X-Visitor-ID", Cookie: "vid", HashIPUA: true}
+	tests := []struct {
+		name string
+		cfg  VisitorConfig
+		prep func(r *http.Request)
+		want uint64
+	}{
+		{"header wins over cookie", cfg, func(r *http.Request) {
+			r.Header.Set("X-Visitor-ID", "h1")
+			r.AddCookie(&http.Cookie{Name: "vid", Value: "c1"})
+		}, service.VisitorHash("h1")},
+		{"cookie", cfg, func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "vid", Value: "c1"})
+		}, service.VisitorHash("c1")},
+		{"ip and user agent without port", cfg, func(r *http.Request) {
+			r.RemoteAddr = "10.0.0.1:51234"
+			r.Header.Set("User-Agent", "ua")
+		}, service.VisitorHash("10.0.0.1\x00ua")},
+		{"nothing configured", VisitorConfig{}, func(r *http.Request) {
+			r.Header.Set("X-Visitor-ID", "h1")
+		}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/counter/1", nil)
+			tc.prep(r)
+			s := &Server{visitors: tc.cfg}
+			if got := s.visitor(r); got != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
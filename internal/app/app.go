@@ -3,9 +3,14 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/dayanaadylkhanova/click-counter/internal/adapter/metrics"
 	"github.com/dayanaadylkhanova/click-counter/internal/adapter/store/postgres"
+	"github.com/dayanaadylkhanova/click-counter/internal/adapter/store/wal"
+	"github.com/dayanaadylkhanova/click-counter/internal/adapter/tracing"
 	http_server "github.com/dayanaadylkhanova/click-counter/internal/adapter/transport/http"
 	"github.com/dayanaadylkhanova/click-counter/internal/service"
 	"github.com/dayanaadylkhanova/click-counter/pkg/config"
@@ -24,34 +29,170 @@ type App struct {
 	info *AppInfo
 	log  *zap.Logger
 
-	store      *postgres.Store
-	aggregator *service.Aggregator
-	server     *http_server.Server
+	store   *postgres.Store
+	journal *wal.Log
+	spill   *wal.Log // события сверх лимита ключей агрегатора (AGG_OVERFLOW_POLICY=spill)
+	// stopTracing отправляет накопленные спаны при остановке.
+	stopTracing func(context.Context) error
+	aggregator  *service.Aggregator
+	banners     *service.Banners
+	window      *service.EventWindow
+	server      *http_server.Server
 }
 
-func New(cfg config.Config, info *AppInfo, log *zap.Logger) (*App, error) {
+// windowReportEvery — период записи в лог событий вне окна времени.
+const windowReportEvery = time.Minute
+
+func New(cfg config.Config, info *AppInfo, log *zap.Logger) (_ *App, err error) {
+	// Открытые ресурсы закрываются в обратном порядке, если New возвращает ошибку
+	var cleanup []func()
+	defer func() {
+		if err != nil {
+			for i := len(cleanup) - 1; i >= 0; i-- {
+				cleanup[i]()
+			}
+		}
+	}()
+
+	// 0) Параметры сервисного слоя из конфига
+	limits := make(map[service.Granularity]int, len(cfg.ReadMaxRangeDaysBy))
+	for g, d := range cfg.ReadMaxRangeDaysBy {
+		gr, err := service.ParseGranularity(g)
+		if err != nil {
+			return nil, fmt.Errorf("READ_MAX_RANGE_DAYS_BY: %w: %s", err, g)
+		}
+		limits[gr] = d
+	}
+	policy, err := service.ParseLatePolicy(cfg.EventLatePolicy)
+	if err != nil {
+		return nil, err
+	}
+	bannerPolicy, err := service.ParseBannerPolicy(cfg.BannerUnknownPolicy)
+	if err != nil {
+		return nil, err
+	}
+	overflow, err := service.ParseOverflowPolicy(cfg.AggOverflowPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0.1) Трассировка (без TRACING_ENDPOINT — только проброс W3C trace context)
+	stopTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+		ServiceName: info.Name,
+		Version:     info.Release,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+	cleanup = append(cleanup, func() { _ = stopTracing(context.Background()) })
+
 	// 1) Store (Postgres)
 	st, err := postgres.New(cfg.DatabaseURL, log)
 	if err != nil {
 		return nil, err
 	}
+	cleanup = append(cleanup, st.Close)
 	if err := st.Init(context.Background()); err != nil {
 		return nil, err
 	}
 
+	// 1.1) Метрики и спаны (запись и чтение хранилища — через обертки)
+	m := metrics.New()
+	m.Pool(st.PoolStat)
+
 	// 2) Aggregator
-	agg := service.NewAggregator(log, st, cfg.Shards, cfg.FlushEvery)
+	agg := service.NewAggregator(log, tracing.Writer(m.Writer(st)), cfg.Shards, cfg.FlushEvery)
+	agg.UseObserver(m)
+	agg.UseTracer(tracing.Flush())
+	agg.SetFlushPolicy(service.FlushPolicy{
+		BaseDelay:        cfg.FlushBackoffBase,
+		MaxDelay:         cfg.FlushBackoffMax,
+		BreakerThreshold: cfg.FlushBreakerThreshold,
+		BreakerCooldown:  cfg.FlushBreakerCooldown,
+	})
+	agg.Limit(cfg.AggMaxPendingKeys, overflow)
+	m.PendingKeys(agg.PendingKeys)
+	m.Breaker(agg.BreakerState)
+	m.CounterFunc("poisoned_rows_total", "Aggregate rows dropped because the store rejected them.", agg.Poisoned)
+	m.CounterFunc("dropped_clicks_total", "Clicks evicted by the drop_oldest overflow policy.", func() int64 { c, _ := agg.Dropped(); return c })
+	m.CounterFunc("dropped_impressions_total", "Impressions evicted by the drop_oldest overflow policy.", func() int64 { _, i := agg.Dropped(); return i })
+	m.CounterFunc("spilled_events_total", "Events written to the spill log over the pending keys limit.", agg.Spilled)
+
+	// 2.1) Write-ahead журнал (опционально)
+	var journal *wal.Log
+	if cfg.WALDir != "" {
+		journal, err = wal.Open(cfg.WALDir, cfg.WALSyncEvery, cfg.WALSyncBatch, log)
+		if err != nil {
+			return nil, err
+		}
+		cleanup = append(cleanup, func() { _ = journal.Close() })
+		if err := agg.UseJournal(journal); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2.2) Spill-журнал событий сверх лимита ключей (AGG_OVERFLOW_POLICY=spill)
+	var spill *wal.Log
+	if overflow == service.OverflowSpill {
+		spill, err = wal.Open(cfg.AggSpillDir, cfg.WALSyncEvery, cfg.WALSyncBatch, log)
+		if err != nil {
+			return nil, err
+		}
+		cleanup = append(cleanup, func() { _ = spill.Close() })
+		// Короткие сегменты: за один flush возвращается не больше AGG_SPILL_DRAIN_BATCH записей
+		spill.SegmentRecords(cfg.AggSpillDrainBatch)
+		agg.UseSpill(spill, cfg.AggSpillDrainBatch)
+	}
+
+	// 3) Stats (bucketing поверх StatsReaderPort + несброшенные данные агрегатора)
+	dims := service.NewDimensions(cfg.Dimensions)
+	stats := service.NewStats(tracing.Reader(m.Reader(st)), agg, dims, cfg.ReadMaxRangeDays, limits, cfg.StatsMaxBanners)
+
+	// 4) Окно допустимого клиентского времени событий
+	window := service.NewEventWindow(cfg.EventLateness, cfg.EventFutureSkew, policy)
+
+	// 4.1) Реестр баннеров (кэш в памяти, обновляется в Run)
+	banners := service.NewBanners(log, st, bannerPolicy)
+	if err := banners.Load(context.Background()); err != nil {
+		return nil, err
+	}
+
+	m.CounterFunc("late_events_total", "Events older than the allowed lateness.", window.LateEvents)
+	m.CounterFunc("future_events_total", "Events beyond the allowed future skew.", window.FutureEvents)
+	m.CounterFunc("dimension_overflow_total", "Dimension values counted as other over the cardinality limit.", dims.Overflow)
+	m.CounterFunc("quarantined_events_total", "Events of unknown or archived banners counted in the quarantine banner.", banners.Quarantined)
+
+	// 4.2) Кампании (статистика — суммы рядов баннеров через Stats)
+	campaigns := service.NewCampaigns(st, stats)
 
-	// 3) HTTP server (ports: AggregatorPort + StatsReaderPort)
-	srv := http_server.NewServer(log, cfg.ListenAddr, agg, st, cfg.ReadMaxRangeDays)
+	// 4.3) Готовность (/readyz): БД, давность flush, объем несохраненных данных
+	health := service.NewHealth(st, agg, cfg.ReadyDBTimeout, cfg.ReadyMaxFlushAge, cfg.ReadyMaxPendingKeys)
+
+	// 5) HTTP server (ports: AggregatorPort + StatsPort + BannerRegistryPort + CampaignPort + HealthPort, /metrics)
+	visitors := http_server.VisitorConfig{
+		Header:   cfg.VisitorHeader,
+		Cookie:   cfg.VisitorCookie,
+		HashIPUA: cfg.VisitorHashIPUA,
+	}
+	srv := http_server.NewServer(log, cfg.ListenAddr, agg, stats, window, dims, visitors, banners, campaigns, health, m)
+	srv.UseAdminToken(cfg.AdminToken)
+	srv.UseMaxItemCount(cfg.BatchMaxItemCount)
 
 	return &App{
-		cfg:        cfg,
-		info:       info,
-		log:        log,
-		store:      st,
-		aggregator: agg,
-		server:     srv,
+		cfg:         cfg,
+		info:        info,
+		log:         log,
+		store:       st,
+		journal:     journal,
+		spill:       spill,
+		stopTracing: stopTracing,
+		aggregator:  agg,
+		banners:     banners,
+		window:      window,
+		server:      srv,
 	}, nil
 }
 
@@ -60,6 +201,8 @@ func (a *App) Run(ctx context.Context) error {
 	bgCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	go a.aggregator.Run(bgCtx)
+	go a.banners.Run(bgCtx, a.cfg.BannersRefreshEvery)
+	go a.window.Report(bgCtx, a.log, windowReportEvery)
 
 	// Start HTTP
 	httpErrCh := make(chan error, 1)
@@ -83,7 +226,20 @@ func (a *App) Run(ctx context.Context) error {
 	defer cancelShutdown()
 	_ = a.server.Shutdown(shutdownCtx)
 	a.aggregator.Stop(shutdownCtx)
+	if a.journal != nil {
+		if err := a.journal.Close(); err != nil {
+			a.log.Warn("journal close", zap.Error(err))
+		}
+	}
+	if a.spill != nil {
+		if err := a.spill.Close(); err != nil {
+			a.log.Warn("spill close", zap.Error(err))
+		}
+	}
 	a.store.Close()
+	if err := a.stopTracing(shutdownCtx); err != nil {
+		a.log.Warn("tracing shutdown", zap.Error(err))
+	}
 
 	return runErr
 }
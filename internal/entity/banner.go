@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// BannerRequest — тело POST /banners и PUT /banners/{bannerID} (id берется из пути).
+type BannerRequest struct {
+	ID        int64  `json:"id,omitempty"`
+	Name      string `json:"name"`
+	TargetURL string `json:"target_url"`
+}
+
+type Banner struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	TargetURL  string     `json:"target_url"`
+	Archived   bool       `json:"archived"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type BannerList struct {
+	Banners []Banner `json:"banners"`
+}
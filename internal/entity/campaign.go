@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// CampaignRequest — тело POST /campaigns.
+type CampaignRequest struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Advertiser string `json:"advertiser,omitempty"`
+}
+
+type Campaign struct {
+	ID         int64            `json:"id"`
+	Name       string           `json:"name"`
+	Advertiser string           `json:"advertiser,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Members    []CampaignMember `json:"members,omitempty"`
+}
+
+// CampaignMember — баннер входит в кампанию на [from, to); без to — по сей день.
+type CampaignMember struct {
+	BannerID int64      `json:"banner_id"`
+	From     time.Time  `json:"from"`
+	To       *time.Time `json:"to,omitempty"`
+}
+
+type CampaignList struct {
+	Campaigns []Campaign `json:"campaigns"`
+}
+
+// CampaignBannerRequest — тело POST /campaigns/{campaignID}/banners; без from — с текущего момента.
+type CampaignBannerRequest struct {
+	BannerID int64  `json:"banner_id"`
+	From     string `json:"from,omitempty"`
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "encoding/json"
+
+// BatchItem — один элемент POST /counter/batch.
+type BatchItem struct {
+	BannerID json.Number       `json:"banner_id"`
+	TS       string            `json:"ts,omitempty"`      // время клика (RFC3339 или unix), по умолчанию — время приема
+	Count    int64             `json:"count,omitempty"`   // по умолчанию 1
+	Dims     map[string]string `json:"dims,omitempty"`    // значения измерений
+	Visitor  string            `json:"visitor,omitempty"` // идентификатор посетителя для уникальных
+	Kind     string            `json:"kind,omitempty"`    // click (по умолчанию) или impression
+}
+
+// BatchItemResult — результат обработки элемента с тем же индексом.
+type BatchItemResult struct {
+	Index    int    `json:"index"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Results  []BatchItemResult `json:"results"`
+}
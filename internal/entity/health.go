@@ -0,0 +1,16 @@
+package entity
+
+// Readiness — тело ответа /readyz.
+type Readiness struct {
+	Status string        `json:"status"` // ok или fail
+	Checks []HealthCheck `json:"checks"`
+}
+
+// HealthCheck — результат одной проверки готовности.
+type HealthCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Observed  string `json:"observed,omitempty"`  // измеренное значение
+	Threshold string `json:"threshold,omitempty"` // порог, при превышении которого проверка не проходит
+	Error     string `json:"error,omitempty"`
+}
@@ -1,17 +1,123 @@
 package entity
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type StatsRequest struct {
-	From string `json:"from"`
-	To   string `json:"to"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Granularity string `json:"granularity,omitempty"`
+	Fill        string `json:"fill,omitempty"`
+	// IncludePending — добавить клики, еще не записанные в БД (read-your-writes).
+	IncludePending bool `json:"include_pending,omitempty"`
+	// GroupBy — измерения, по которым разбить ряд; Filter — значения измерений для отбора.
+	GroupBy []string          `json:"group_by,omitempty"`
+	Filter  map[string]string `json:"filter,omitempty"`
+	// Uniques — добавить приблизительное число уникальных посетителей (HyperLogLog).
+	Uniques bool `json:"uniques,omitempty"`
+	// Summary — добавить сводку по возвращаемому ряду (итог, min/max/avg, пик, перцентили).
+	Summary bool `json:"summary,omitempty"`
+	// Compare — сравнить с previous_period, previous_week, previous_year или диапазоном,
+	// сдвинутым назад на смещение ("36h", "7d", "2w").
+	Compare string `json:"compare,omitempty"`
+	// TZ — часовой пояс IANA ("Asia/Almaty") для границ бакетов и меток времени,
+	// а также для from/to без смещения; по умолчанию UTC.
+	TZ string `json:"tz,omitempty"`
+}
+
+// MultiStatsRequest — тело POST /stats: параметры как у StatsRequest для всех баннеров.
+type MultiStatsRequest struct {
+	StatsRequest
+	BannerIDs []int64 `json:"banner_ids"`
 }
 
 type Point struct {
-	TS time.Time `json:"ts"`
-	V  int64     `json:"v"`
+	TS          time.Time `json:"ts"`
+	V           int64     `json:"v"`                     // клики
+	Impressions int64     `json:"impressions,omitempty"` // показы
+	CTR         *float64  `json:"ctr,omitempty"`         // клики / показы, только при показах > 0
+	Uniques     *int64    `json:"uniques,omitempty"`     // только при uniques=true
+	Null        bool      `json:"-"`                     // бакет без данных при fill=null, сериализуется как "v": null
+}
+
+func (p Point) MarshalJSON() ([]byte, error) {
+	if p.Null {
+		return json.Marshal(struct {
+			TS time.Time `json:"ts"`
+			V  *int64    `json:"v"`
+		}{TS: p.TS})
+	}
+	type plain Point
+	return json.Marshal(plain(p))
 }
 
 type StatsResponse struct {
-	Stats []Point `json:"stats"`
+	Stats  []Point      `json:"stats"`
+	Groups []GroupStats `json:"groups,omitempty"`
+	// Uniques — уникальные посетители за весь диапазон (не сумма по бакетам).
+	Uniques *int64   `json:"uniques,omitempty"`
+	Summary *Summary `json:"summary,omitempty"`
+	// Compare — сравнение с предыдущим диапазоном (при compare).
+	Compare *Comparison `json:"compare,omitempty"`
+}
+
+// Comparison — ряд, сопоставленный с рядом за [From, To) по номеру бакета.
+type Comparison struct {
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Points []ComparePoint `json:"points"`
+	Total  Delta          `json:"total"`
+}
+
+// ComparePoint — бакет TS текущего диапазона и бакет PrevTS с тем же номером Offset в предыдущем.
+type ComparePoint struct {
+	Offset int       `json:"offset"`
+	TS     time.Time `json:"ts"`
+	PrevTS time.Time `json:"prev_ts"`
+	Delta
+}
+
+// Delta — клики в текущем и предыдущем диапазонах и их разница.
+type Delta struct {
+	Current  int64    `json:"current"`
+	Previous int64    `json:"previous"`
+	Delta    int64    `json:"delta"`
+	DeltaPct *float64 `json:"delta_pct,omitempty"` // в процентах, нет при previous = 0
+}
+
+// Summary — сводка по кликам бакетов ряда (бакеты fill=null не учитываются).
+type Summary struct {
+	Total       int64      `json:"total"`
+	Impressions int64      `json:"impressions,omitempty"`
+	Buckets     int        `json:"buckets"`
+	Min         int64      `json:"min"`
+	Max         int64      `json:"max"`
+	Avg         float64    `json:"avg"`
+	PeakTS      *time.Time `json:"peak_ts,omitempty"` // первый бакет с максимумом
+	// Percentiles — p50, p90, p95, p99 кликов по бакетам (линейная интерполяция).
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+}
+
+// MultiStatsResponse — ряды по баннерам (ключ — ID баннера).
+type MultiStatsResponse struct {
+	Banners map[int64]*StatsResponse `json:"banners"`
+}
+
+// GroupStats — ряд для одного набора значений измерений из group_by.
+type GroupStats struct {
+	Dims    map[string]string `json:"dims"`
+	Stats   []Point           `json:"stats"`
+	Summary *Summary          `json:"summary,omitempty"`
+}
+
+// TopResponse — баннеры с наибольшим числом кликов, по убыванию.
+type TopResponse struct {
+	Top []TopBanner `json:"top"`
+}
+
+type TopBanner struct {
+	BannerID int64 `json:"banner_id"`
+	Clicks   int64 `json:"clicks"`
 }
@@ -2,28 +2,74 @@ package service
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/axiomhq/hyperloglog"
 	"go.uber.org/zap"
 )
 
 type key struct {
 	banner int64
 	minute int64 // unix minutes since epoch
+	dims   Dims
 }
 
+// counts — значения одного ключа агрегата.
+type counts struct {
+	clicks int64
+	imps   int64
+}
+
+// skey — ключ скетча посетителей: баннер и минута, без измерений.
+type skey struct {
+	banner int64
+	minute int64
+}
+
+// shard — часть несохраненных счетчиков. С журналом инкременты делятся по сегментам:
+// data — записанные в сегмент seg (или позже последней ротации), sealed — из более ранних
+// сегментов и возвращенные после неудачного flush, flushing — снапшот, который пишет flush.
 type shard struct {
-	mu   sync.Mutex
-	data map[key]int64
+	mu       sync.Mutex
+	data     *minutes
+	sealed   *minutes
+	flushing *minutes
+	seg      uint64
+	sketches map[skey]*hyperloglog.Sketch
 }
 
 type Aggregator struct {
-	log        *zap.Logger
-	writer     AggregateWriter
-	shards     []shard
-	flushEvery time.Duration
-	stopCh     chan struct{}
+	log         *zap.Logger
+	writer      AggregateWriter
+	journal     Journal
+	shards      []shard
+	flushEvery  time.Duration
+	flushMu     sync.Mutex
+	flushGen    atomic.Uint64
+	journalErrs atomic.Int64
+	lastFlush   atomic.Int64 // unix nanos последнего успешного flush
+	stopCh      chan struct{}
+	observer    FlushObserver
+	tracer      FlushTracer
+	breaker     *breaker
+	poisoned    atomic.Int64 // строки, отброшенные после ErrBadBatch
+
+	// Лимит несохраненных ключей (см. overflow.go); keys — их текущее число.
+	maxKeys       int64
+	policy        OverflowPolicy
+	spill         SpillLog
+	spillBatch    int
+	keys          atomic.Int64
+	droppedClicks atomic.Int64
+	droppedImps   atomic.Int64
+	spilled       atomic.Int64
+
+	// inflight — скетчи, которые записываются текущим flush (для PendingSketches).
+	inflightMu sync.Mutex
+	inflight   []map[skey]*hyperloglog.Sketch
 }
 
 func NewAggregator(log *zap.Logger, w AggregateWriter, shardCount int, flushEvery time.Duration) *Aggregator {
@@ -35,9 +81,59 @@ func NewAggregator(log *zap.Logger, w AggregateWriter, shardCount int, flushEver
 	}
 	shards := make([]shard, shardCount)
 	for i := range shards {
-		shards[i] = shard{data: make(map[key]int64, 1024)}
+		shards[i] = shard{data: newMinutes(), sketches: make(map[skey]*hyperloglog.Sketch)}
 	}
-	return &Aggregator{log: log, writer: w, shards: shards, flushEvery: flushEvery, stopCh: make(chan struct{})}
+	a := &Aggregator{log: log, writer: w, shards: shards, flushEvery: flushEvery, stopCh: make(chan struct{}), breaker: newBreaker(FlushPolicy{})}
+	a.lastFlush.Store(time.Now().UnixNano())
+	return a
+}
+
+// UseJournal восстанавливает в шарды инкременты, не записанные в БД до рестарта,
+// и включает журналирование. Вызывается до Run и до первого Inc.
+// Отрицательные записи — вытеснения drop_oldest (см. evictOldest): они снимают со счетчика
+// ключа то, что от него осталось в журнале, но не больше.
+func (a *Aggregator) UseJournal(j Journal) error {
+	var n int
+	err := j.Replay(func(ev Event) {
+		k := key{banner: ev.BannerID, minute: bucket(ev.TS), dims: ev.Dims}
+		sh := &a.shards[a.shardIndex(k)]
+		switch {
+		case ev.Count >= 0:
+			a.keys.Add(int64(sh.apply(k, ev)))
+		case ev.Kind == KindImpression && sh.data.sub(k, counts{imps: -ev.Count}):
+			a.keys.Add(-1)
+		case ev.Kind != KindImpression && sh.data.sub(k, counts{clicks: -ev.Count}):
+			a.keys.Add(-1)
+		}
+		n++
+	})
+	if err != nil {
+		return err
+	}
+	a.journal = j
+	if n > 0 {
+		a.log.Info("journal replayed", zap.Int("records", n))
+	}
+	return nil
+}
+
+// SetFlushPolicy задает задержки повторов flush и предохранитель. Вызывается до Run.
+func (a *Aggregator) SetFlushPolicy(p FlushPolicy) { a.breaker = newBreaker(p) }
+
+// BreakerState implements FlushStatusPort: состояние предохранителя и число ошибок flush подряд.
+func (a *Aggregator) BreakerState() (BreakerState, int) { return a.breaker.current() }
+
+// Poisoned — число строк агрегата, отброшенных, потому что хранилище их не принимает.
+func (a *Aggregator) Poisoned() int64 { return a.poisoned.Load() }
+
+// UseObserver подключает наблюдателя за flush (метрики). Вызывается до Run.
+func (a *Aggregator) UseObserver(o FlushObserver) { a.observer = o }
+
+// UseTracer подключает трассировку flush. Вызывается до Run.
+func (a *Aggregator) UseTracer(t FlushTracer) { a.tracer = t }
+
+func (k key) row(c counts) AggregateRow {
+	return AggregateRow{BannerID: k.banner, TS: time.Unix(k.minute*60, 0).UTC(), Dims: k.dims, Cnt: c.clicks, Imps: c.imps}
 }
 
 func minuteUTC(t time.Time) time.Time { return t.UTC().Truncate(time.Minute) }
@@ -48,41 +144,342 @@ func (a *Aggregator) shardIndex(k key) int {
 	return int(x % uint64(len(a.shards)))
 }
 
-func (a *Aggregator) Inc(bannerID int64, now time.Time) {
-	k := key{banner: bannerID, minute: bucket(now)}
+func (a *Aggregator) Inc(bannerID int64, now time.Time) error {
+	return a.Add(Event{BannerID: bannerID, TS: now, Count: 1})
+}
+
+// Add учитывает ev.Count кликов или показов в минуте ev.TS и посетителя ev.Visitor
+// (посетители считаются только по кликам). Сверх лимита ключей поведение задает
+// OverflowPolicy: ErrOverloaded, запись в spill или вытеснение старых минут.
+func (a *Aggregator) Add(ev Event) error {
+	k := key{banner: ev.BannerID, minute: bucket(ev.TS), dims: ev.Dims}
 	sh := &a.shards[a.shardIndex(k)]
 	sh.mu.Lock()
-	sh.data[k]++
+	if err := a.reserve(sh, k, ev); err != nil {
+		sh.mu.Unlock()
+		switch err {
+		case errSpill:
+			return a.spillEvent(ev)
+		case errDrop:
+			a.drop(ev)
+			return nil
+		}
+		return err
+	}
+	if a.journal != nil {
+		// Пишем в журнал под локом шарда: по номеру сегмента записи видно,
+		// к какому снапшоту относится инкремент (см. seal и snapshot).
+		ev.TS = time.Unix(k.minute*60, 0).UTC()
+		if seg, err := a.journal.Append(ev); err != nil {
+			a.journalErrs.Add(1)
+		} else if seg > sh.seg {
+			a.seal(sh, seg)
+		}
+	}
+	a.keys.Add(int64(sh.apply(k, ev)))
 	sh.mu.Unlock()
+	return nil
 }
 
-func (a *Aggregator) buildBatchSnapshot() []AggregateRow {
-	// Снять снапшот под локами, очистить только после успешной записи
-	tmp := make([]map[key]int64, len(a.shards))
+// apply вызывается под локом шарда (или до старта, при replay журнала).
+// Возвращает, сколько ключей и скетчей появилось в шарде.
+func (sh *shard) apply(k key, ev Event) int {
+	var added int
+	if ev.Kind == KindImpression {
+		if sh.data.add(k, counts{imps: ev.Count}) {
+			added++
+		}
+		return added
+	}
+	if sh.data.add(k, counts{clicks: ev.Count}) {
+		added++
+	}
+	if ev.Visitor != 0 {
+		sk := skey{banner: k.banner, minute: k.minute}
+		hll := sh.sketches[sk]
+		if hll == nil {
+			hll = hyperloglog.New()
+			sh.sketches[sk] = hll
+			added++
+		}
+		hll.InsertHash(ev.Visitor)
+	}
+	return added
+}
+
+// seal переносит data в sealed: запись шарда попала в новый сегмент журнала seg,
+// значит все, что уже есть в data, записано в предыдущие сегменты.
+func (a *Aggregator) seal(sh *shard, seg uint64) {
+	sh.seg = seg
+	if sh.data.len() == 0 {
+		return
+	}
+	if sh.sealed == nil {
+		sh.sealed = sh.data
+	} else {
+		a.keys.Add(-int64(sh.sealed.merge(sh.data)))
+	}
+	sh.data = newMinutes()
+}
+
+// snapshot забирает из шардов счетчики, записанные в журнал до ротации, и накопленные скетчи.
+// Журнал ротируется до блокировки шардов, шарды блокируются по одному и только на обмен карт.
+// Шард, еще не писавший в новый сегмент, отдает и data; иначе data уже относится к новому
+// сегменту и остается до следующего flush. Без журнала шард отдает все.
+func (a *Aggregator) snapshot() ([]*minutes, []map[skey]*hyperloglog.Sketch, uint64) {
+	var checkpoint uint64
+	if a.journal != nil {
+		cp, err := a.journal.Rotate()
+		if err != nil {
+			a.log.Warn("journal rotate failed", zap.Error(err))
+		} else {
+			checkpoint = cp
+		}
+	}
+
+	tmp := make([]*minutes, len(a.shards))
+	sk := make([]map[skey]*hyperloglog.Sketch, len(a.shards))
 	for i := range a.shards {
 		sh := &a.shards[i]
 		sh.mu.Lock()
-		if len(sh.data) > 0 {
-			m := make(map[key]int64, len(sh.data))
-			for k, v := range sh.data {
-				m[k] = v
+		m := sh.sealed
+		sh.sealed = nil
+		if (checkpoint == 0 || sh.seg < checkpoint) && sh.data.len() > 0 {
+			if m == nil {
+				m = sh.data
+			} else {
+				a.keys.Add(-int64(m.merge(sh.data)))
 			}
-			tmp[i] = m
+			sh.data = newMinutes()
+		}
+		if checkpoint > sh.seg {
+			sh.seg = checkpoint
+		}
+		if m.len() > 0 {
+			sh.flushing, tmp[i] = m, m
+		}
+		if len(sh.sketches) > 0 {
+			sk[i] = sh.sketches
+			sh.sketches = make(map[skey]*hyperloglog.Sketch)
 		}
 		sh.mu.Unlock()
 	}
+	return tmp, sk, checkpoint
+}
+
+func batchOf(tmp []*minutes) []AggregateRow {
 	var batch []AggregateRow
 	for i := range tmp {
-		if tmp[i] == nil {
+		tmp[i].each(func(k key, v counts) { batch = append(batch, k.row(v)) })
+	}
+	return batch
+}
+
+// release завершает запись снапшота: записанные строки done удаляются из шардов,
+// остальные возвращаются в sealed до следующего flush.
+func (a *Aggregator) release(done []AggregateRow) {
+	byShard := make([][]AggregateRow, len(a.shards))
+	for _, r := range done {
+		i := a.shardIndex(key{banner: r.BannerID, minute: bucket(r.TS)})
+		byShard[i] = append(byShard[i], r)
+	}
+	for i := range a.shards {
+		sh := &a.shards[i]
+		sh.mu.Lock()
+		if m := sh.flushing; m != nil {
+			for _, r := range byShard[i] {
+				if m.remove(key{banner: r.BannerID, minute: bucket(r.TS), dims: r.Dims}) {
+					a.keys.Add(-1)
+				}
+			}
+			switch {
+			case m.len() == 0:
+			case sh.sealed == nil:
+				sh.sealed = m
+			default:
+				a.keys.Add(-int64(sh.sealed.merge(m)))
+			}
+			sh.flushing = nil
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// compact заменяет сегменты журнала до checkpoint строками снапшота, которые не удалось
+// записать: после рестарта replay не повторит записанные строки и не вернет отброшенные
+// (ErrBadBatch). Новые записи синхронизируются до Commit. Если компактировать не удалось,
+// старые сегменты остаются: повтор после падения возможен, потери — нет.
+func (a *Aggregator) compact(batch, done []AggregateRow, checkpoint uint64) {
+	written := make(map[key]struct{}, len(done))
+	for _, r := range done {
+		written[key{banner: r.BannerID, minute: bucket(r.TS), dims: r.Dims}] = struct{}{}
+	}
+	for _, r := range batch {
+		if _, ok := written[key{banner: r.BannerID, minute: bucket(r.TS), dims: r.Dims}]; ok {
 			continue
 		}
-		for k, v := range tmp[i] {
-			batch = append(batch, AggregateRow{BannerID: k.banner, TS: time.Unix(k.minute*60, 0).UTC(), Cnt: v})
+		for _, ev := range r.events() {
+			if _, err := a.journal.Append(ev); err != nil {
+				a.log.Warn("journal compaction failed", zap.Error(err))
+				return
+			}
 		}
 	}
-	return batch
+	if err := a.journal.Sync(); err != nil {
+		a.log.Warn("journal compaction failed", zap.Error(err))
+		return
+	}
+	if err := a.journal.Commit(checkpoint); err != nil {
+		a.log.Warn("journal commit failed", zap.Error(err))
+	}
+}
+
+// events — события журнала, из которых складывается строка r.
+func (r AggregateRow) events() []Event {
+	var out []Event
+	if r.Cnt != 0 {
+		out = append(out, Event{BannerID: r.BannerID, TS: r.TS, Count: r.Cnt, Dims: r.Dims})
+	}
+	if r.Imps != 0 {
+		out = append(out, Event{BannerID: r.BannerID, TS: r.TS, Count: r.Imps, Dims: r.Dims, Kind: KindImpression})
+	}
+	return out
+}
+
+// upsert записывает rows и возвращает строки, которые можно вычесть из шардов.
+// Батч, отвергнутый хранилищем (ErrBadBatch), делится пополам, чтобы одна испорченная
+// строка не блокировала остальные; отвергнутая одиночная строка отбрасывается.
+// При другой ошибке возвращаются уже записанные части и ошибка.
+func (a *Aggregator) upsert(ctx context.Context, rows []AggregateRow) ([]AggregateRow, error) {
+	err := a.writer.UpsertAggregates(ctx, rows)
+	switch {
+	case err == nil:
+		return rows, nil
+	case !errors.Is(err, ErrBadBatch):
+		return nil, err
+	case len(rows) == 1:
+		a.poisoned.Add(1)
+		a.log.Error("aggregate row dropped", zap.Int64("banner_id", rows[0].BannerID), zap.Time("ts", rows[0].TS),
+			zap.String("dims", string(rows[0].Dims)), zap.Int64("clicks", rows[0].Cnt), zap.Int64("impressions", rows[0].Imps), zap.Error(err))
+		return rows, nil
+	}
+	mid := len(rows) / 2
+	done, err := a.upsert(ctx, rows[:mid])
+	if err != nil {
+		return done, err
+	}
+	rest, err := a.upsert(ctx, rows[mid:])
+	return append(done, rest...), err
+}
+
+func (a *Aggregator) flush(ctx context.Context) (err error) {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	// Забрать снапшот из шардов; незаписанное вернется в sealed (release)
+	tmp, sk, checkpoint := a.snapshot()
+	a.setInflight(sk)
+	defer a.setInflight(nil)
+
+	batch, sketches := batchOf(tmp), a.sketchRowsOf(sk)
+	if len(batch) > 0 || len(sketches) > 0 {
+		if a.observer != nil {
+			start := time.Now()
+			defer func() { a.observer.ObserveFlush(len(batch), time.Since(start), err) }()
+		}
+		if a.tracer != nil {
+			var end func(int, error)
+			ctx, end = a.tracer.StartFlush(ctx)
+			defer func() { end(len(batch), err) }()
+		}
+	}
+
+	// Скетчи пишутся первыми: их повторное слияние безопасно, а повтор счетчиков — нет
+	if len(sketches) > 0 {
+		if err := a.writer.MergeSketches(ctx, sketches); err != nil {
+			a.restoreSketches(sk)
+			a.release(nil)
+			return err
+		}
+	}
+	if len(batch) > 0 {
+		a.flushGen.Add(1)
+		done, err := a.upsert(ctx, batch)
+		if err != nil && len(done) > 0 && checkpoint > 0 {
+			// Записанные строки уходят из памяти, поэтому журнал должен их забыть раньше
+			a.compact(batch, done, checkpoint)
+		}
+		a.release(done)
+		a.flushGen.Add(1)
+		if err != nil {
+			a.restoreSketches(sk)
+			return err
+		}
+	}
+	if checkpoint > 0 {
+		if err := a.journal.Commit(checkpoint); err != nil {
+			a.log.Warn("journal commit failed", zap.Error(err))
+		}
+	}
+	a.keys.Add(-int64(sketchCount(sk)))
+	a.lastFlush.Store(time.Now().UnixNano())
+	return nil
 }
 
+// LastFlush implements FlushStatusPort: время последнего успешного flush
+// (включая пустые; до первого — время создания агрегатора).
+func (a *Aggregator) LastFlush() time.Time { return time.Unix(0, a.lastFlush.Load()) }
+
+// PendingKeys implements FlushStatusPort: число несохраненных ключей агрегата в каждом шарде.
+func (a *Aggregator) PendingKeys() []int {
+	out := make([]int, len(a.shards))
+	for i := range a.shards {
+		sh := &a.shards[i]
+		sh.mu.Lock()
+		out[i] = sh.data.len() + sh.sealed.len() + sh.flushing.len()
+		sh.mu.Unlock()
+	}
+	return out
+}
+
+// FlushGen implements PendingReaderPort
+func (a *Aggregator) FlushGen() uint64 { return a.flushGen.Load() }
+
+// Pending implements PendingReaderPort: поминутные строки баннеров ids из шардов,
+// включая батчи, которые не удалось записать. Каждый шард просматривается один раз;
+// один ключ может дать несколько строк (из data, sealed и flushing).
+func (a *Aggregator) Pending(ids []int64, from, to time.Time) ([]AggregateRow, uint64) {
+	lo, hi, want := bucket(from), bucket(to), bannerSet(ids)
+	var rows []AggregateRow
+	for i := range a.shards {
+		sh := &a.shards[i]
+		sh.mu.Lock()
+		sh.each(func(k key, v counts) {
+			if _, ok := want[k.banner]; ok && k.minute >= lo && k.minute < hi {
+				rows = append(rows, k.row(v))
+			}
+		})
+		sh.mu.Unlock()
+	}
+	return rows, a.flushGen.Load()
+}
+
+// each обходит все несохраненные счетчики шарда; вызывается под локом шарда.
+func (sh *shard) each(fn func(k key, v counts)) {
+	sh.data.each(fn)
+	sh.sealed.each(fn)
+	sh.flushing.each(fn)
+}
+
+func bannerSet(ids []int64) map[int64]struct{} {
+	set := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+// Run пишет агрегаты раз в flushEvery; после ошибок попытки реже (FlushPolicy).
 func (a *Aggregator) Run(ctx context.Context) {
 	t := time.NewTicker(a.flushEvery)
 	defer t.Stop()
@@ -92,45 +489,19 @@ func (a *Aggregator) Run(ctx context.Context) {
 			return
 		case <-a.stopCh:
 			return
-		case <-t.C:
-			// Снимем снапшот и попробуем записать
-			tmp := make([]map[key]int64, len(a.shards))
-			for i := range a.shards {
-				sh := &a.shards[i]
-				sh.mu.Lock()
-				if len(sh.data) > 0 {
-					m := make(map[key]int64, len(sh.data))
-					for k, v := range sh.data {
-						m[k] = v
-					}
-					tmp[i] = m
-				}
-				sh.mu.Unlock()
-			}
-			var batch []AggregateRow
-			for i := range tmp {
-				if tmp[i] == nil {
-					continue
-				}
-				for k, v := range tmp[i] {
-					batch = append(batch, AggregateRow{BannerID: k.banner, TS: time.Unix(k.minute*60, 0).UTC(), Cnt: v})
+		case now := <-t.C:
+			if a.breaker.allow(now) {
+				err := a.flush(ctx)
+				if wait := a.breaker.record(now, err); err != nil {
+					state, failures := a.breaker.current()
+					a.log.Warn("flush failed", zap.Error(err), zap.Int("failures", failures),
+						zap.String("breaker", string(state)), zap.Duration("retry_in", wait))
+				} else {
+					a.drainSpill()
 				}
 			}
-			if len(batch) == 0 {
-				continue
-			}
-			if err := a.writer.UpsertAggregates(ctx, batch); err != nil {
-				a.log.Warn("flush failed", zap.Error(err))
-				continue
-			}
-			// Очистка оригинальных карт только после успеха
-			for i := range a.shards {
-				sh := &a.shards[i]
-				sh.mu.Lock()
-				for k := range tmp[i] {
-					delete(sh.data, k)
-				}
-				sh.mu.Unlock()
+			if n := a.journalErrs.Swap(0); n > 0 {
+				a.log.Warn("journal append failed", zap.Int64("count", n))
 			}
 		}
 	}
@@ -139,8 +510,7 @@ func (a *Aggregator) Run(ctx context.Context) {
 func (a *Aggregator) Stop(ctx context.Context) {
 	close(a.stopCh)
 	// Финальный flush (best-effort)
-	snap := a.buildBatchSnapshot()
-	if len(snap) > 0 {
-		_ = a.writer.UpsertAggregates(ctx, snap)
+	if err := a.flush(ctx); err != nil {
+		a.log.Warn("final flush failed", zap.Error(err))
 	}
 }
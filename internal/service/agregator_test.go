@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -216,3 +217,203 @@ func TestAggregator_ConcurrentInc_CountsAreAccurate(t *testing.T) {
 	agg.Stop(context.Background())
 	waitCh(t, done, 300*time.Millisecond)
 }
+
+func TestAggregator_Journal_ReplayAndCommit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockW := NewMockAggregateWriter(ctrl)
+	mockJ := NewMockJournal(ctrl)
+	log := zap.NewNop()
+
+	agg := NewAggregator(log, mockW, 4, time.Hour)
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	// в журнале остались 2 клика с прошлого запуска
+	mockJ.EXPECT().
+		Replay(gomock.Any()).
+		DoAndReturn(func(fn func(Event)) error {
+			fn(Event{BannerID: 9, TS: now, Count: 1})
+			fn(Event{BannerID: 9, TS: now, Count: 1})
+			return nil
+		})
+	if err := agg.UseJournal(mockJ); err != nil {
+		t.Fatalf("use journal: %v", err)
+	}
+
+	gomock.InOrder(
+		mockJ.EXPECT().Append(Event{BannerID: 9, TS: now, Count: 1}).Return(uint64(2), nil),
+		mockJ.EXPECT().Rotate().Return(uint64(3), nil),
+		mockW.EXPECT().
+			UpsertAggregates(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, rows []AggregateRow) error {
+				if len(rows) != 1 || rows[0].Cnt != 3 {
+					t.Fatalf("expected replayed + new clicks in one row, got %#v", rows)
+				}
+				return nil
+			}),
+		mockJ.EXPECT().Commit(uint64(3)).Return(nil),
+	)
+
+	agg.Inc(9, now.Add(30*time.Second))
+	agg.Stop(context.Background())
+}
+
+func TestAggregator_Journal_NewSegmentWaitsForNextFlush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockW := NewMockAggregateWriter(ctrl)
+	mockJ := NewMockJournal(ctrl)
+	mockJ.EXPECT().Replay(gomock.Any()).Return(nil)
+	agg := NewAggregator(zap.NewNop(), mockW, 1, time.Hour)
+	if err := agg.UseJournal(mockJ); err != nil {
+		t.Fatalf("use journal: %v", err)
+	}
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	mockJ.EXPECT().Append(Event{BannerID: 1, TS: now, Count: 1}).Return(uint64(1), nil)
+	_ = agg.Inc(1, now)
+
+	// клик попадает в новый сегмент между ротацией и снапшотом шарда
+	gomock.InOrder(
+		mockJ.EXPECT().Rotate().DoAndReturn(func() (uint64, error) {
+			mockJ.EXPECT().Append(Event{BannerID: 2, TS: now, Count: 1}).Return(uint64(2), nil)
+			_ = agg.Inc(2, now)
+			return 2, nil
+		}),
+		mockW.EXPECT().
+			UpsertAggregates(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, rows []AggregateRow) error {
+				if len(rows) != 1 || rows[0].BannerID != 1 {
+					t.Fatalf("expected only the rotated segment, got %#v", rows)
+				}
+				return nil
+			}),
+		mockJ.EXPECT().Commit(uint64(2)).Return(nil),
+	)
+	if err := agg.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows, _ := agg.Pending([]int64{1, 2}, now, now.Add(time.Minute))
+	if len(rows) != 1 || rows[0].BannerID != 2 {
+		t.Fatalf("expected banner 2 to stay pending, got %#v", rows)
+	}
+}
+
+func TestAggregator_Uniques_MergedBeforeCountsAndKeptOnFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockW := NewMockAggregateWriter(ctrl)
+	agg := NewAggregator(zap.NewNop(), mockW, 4, time.Hour)
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	for _, v := range []string{"a", "b", "a"} {
+		agg.Add(Event{BannerID: 5, TS: now, Count: 1, Visitor: VisitorHash(v)})
+	}
+
+	estimate := func(rows []SketchRow) uint64 {
+		_, total := mergeSketches(rows, GranularityMinute.In(nil))
+		return total.Estimate()
+	}
+	gomock.InOrder(
+		mockW.EXPECT().
+			MergeSketches(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, rows []SketchRow) error {
+				if len(rows) != 1 || estimate(rows) != 2 {
+					t.Fatalf("expected one sketch of 2 visitors, got %d rows", len(rows))
+				}
+				return nil
+			}),
+		mockW.EXPECT().UpsertAggregates(gomock.Any(), gomock.Any()).Return(errors.New("db down")),
+	)
+	if err := agg.flush(context.Background()); err == nil {
+		t.Fatalf("expected flush error")
+	}
+
+	// неудачный flush возвращает скетч в шарды: он виден как pending и записывается повторно
+	if got := estimate(agg.PendingSketches([]int64{5}, now, now.Add(time.Minute))); got != 2 {
+		t.Fatalf("expected 2 pending uniques, got %d", got)
+	}
+	mockW.EXPECT().MergeSketches(gomock.Any(), gomock.Any()).Return(nil)
+	mockW.EXPECT().UpsertAggregates(gomock.Any(), gomock.Any()).Return(nil)
+	if err := agg.flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if rows := agg.PendingSketches([]int64{5}, now, now.Add(time.Minute)); len(rows) != 0 {
+		t.Fatalf("expected no pending sketches, got %d", len(rows))
+	}
+}
+
+func TestAggregator_ObserverAndPendingKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockW := NewMockAggregateWriter(ctrl)
+	obs := NewMockFlushObserver(ctrl)
+	agg := NewAggregator(zap.NewNop(), mockW, 1, time.Second)
+	agg.UseObserver(obs)
+
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	agg.Inc(1, now)
+	agg.Inc(2, now)
+	if keys := agg.PendingKeys(); len(keys) != 1 || keys[0] != 2 {
+		t.Fatalf("expected 2 pending keys, got %v", keys)
+	}
+
+	gomock.InOrder(
+		mockW.EXPECT().UpsertAggregates(gomock.Any(), gomock.Any()).Return(assertErr),
+		obs.EXPECT().ObserveFlush(2, gomock.Any(), assertErr),
+		mockW.EXPECT().UpsertAggregates(gomock.Any(), gomock.Any()).Return(nil),
+		obs.EXPECT().ObserveFlush(2, gomock.Any(), nil),
+	)
+	if err := agg.flush(context.Background()); err == nil {
+		t.Fatal("expected flush error")
+	}
+	if err := agg.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Пустой flush не наблюдается
+	if err := agg.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keys := agg.PendingKeys(); keys[0] != 0 {
+		t.Fatalf("expected no pending keys, got %v", keys)
+	}
+}
+
+func TestAggregator_TracerWrapsStoreWrites(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type ctxKey struct{}
+	mockW := NewMockAggregateWriter(ctrl)
+	tracer := NewMockFlushTracer(ctrl)
+	agg := NewAggregator(zap.NewNop(), mockW, 1, time.Second)
+	agg.UseTracer(tracer)
+	_ = agg.Inc(1, time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC))
+
+	var ended bool
+	tracer.EXPECT().StartFlush(gomock.Any()).DoAndReturn(func(ctx context.Context) (context.Context, func(int, error)) {
+		return context.WithValue(ctx, ctxKey{}, "flush"), func(rows int, err error) {
+			ended = rows == 1 && err == nil
+		}
+	})
+	mockW.EXPECT().UpsertAggregates(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ []AggregateRow) error {
+		if ctx.Value(ctxKey{}) != "flush" {
+			t.Errorf("store write is outside the flush span")
+		}
+		return nil
+	})
+	if err := agg.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ended {
+		t.Fatal("expected flush span to end with 1 row")
+	}
+	// пустой flush спанов не открывает
+	if err := agg.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
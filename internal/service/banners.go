@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrUnknownBanner       = errors.New("unknown banner")
+	ErrArchivedBanner      = errors.New("archived banner")
+	ErrBannerExists        = errors.New("banner already exists")
+	ErrInvalidBanner       = errors.New("invalid banner")
+	ErrUnknownBannerPolicy = errors.New("unknown banner policy")
+)
+
+// QuarantineBannerID — баннер, в который policy=quarantine складывает события
+// неизвестных и архивных баннеров.
+const QuarantineBannerID int64 = 0
+
+// BannerPolicy — что делать с событием неизвестного или архивного баннера.
+type BannerPolicy string
+
+const (
+	BannerAccept     BannerPolicy = "accept"     // учитывать как есть
+	BannerReject     BannerPolicy = "reject"     // отклонить (404)
+	BannerQuarantine BannerPolicy = "quarantine" // учитывать в QuarantineBannerID
+)
+
+func ParseBannerPolicy(s string) (BannerPolicy, error) {
+	switch p := BannerPolicy(s); p {
+	case BannerAccept, BannerReject, BannerQuarantine:
+		return p, nil
+	default:
+		return "", ErrUnknownBannerPolicy
+	}
+}
+
+// Banner — запись реестра баннеров.
+type Banner struct {
+	ID   int64
+	Name string
+	// TargetURL — адрес перехода (пусто — без редиректа); может содержать макросы
+	// {banner_id}, {ts}, {request_id} и {<измерение>}, которые подставляются при редиректе.
+	TargetURL  string
+	ArchivedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (b Banner) Archived() bool { return b.ArchivedAt != nil }
+
+// Banners — реестр баннеров: изменения пишутся в хранилище и сразу в кэш,
+// а кэш целиком перечитывается по таймеру (изменения с других реплик),
+// поэтому обработка событий не обращается к БД.
+type Banners struct {
+	log    *zap.Logger
+	store  BannerStorePort
+	policy BannerPolicy
+
+	mu          sync.Mutex // сериализует запись в byID
+	byID        atomic.Pointer[map[int64]Banner]
+	version     uint64           // число изменений через put, под mu
+	putAt       map[int64]uint64 // version последнего put баннера, под mu
+	quarantined atomic.Int64
+}
+
+func NewBanners(log *zap.Logger, store BannerStorePort, policy BannerPolicy) *Banners {
+	b := &Banners{log: log, store: store, policy: policy, putAt: make(map[int64]uint64)}
+	empty := map[int64]Banner{}
+	b.byID.Store(&empty)
+	return b
+}
+
+// Load перечитывает реестр. Баннеры, измененные через put во время чтения, остаются
+// в кэше как есть: прочитанная версия может быть старше. Баннер с некорректным адресом
+// остается в реестре без адреса перехода (события учитываются, редирект выключен).
+func (b *Banners) Load(ctx context.Context) error {
+	b.mu.Lock()
+	start := b.version
+	b.mu.Unlock()
+
+	list, err := b.store.ListBanners(ctx)
+	if err != nil {
+		return err
+	}
+	m := make(map[int64]Banner, len(list))
+	for _, bn := range list {
+		if err := ValidateTargetURL(bn.TargetURL); err != nil {
+			b.log.Warn("banner redirect disabled", zap.Int64("banner_id", bn.ID), zap.String("target_url", bn.TargetURL), zap.Error(err))
+			bn.TargetURL = ""
+		}
+		m[bn.ID] = bn
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cur := *b.byID.Load()
+	for id, v := range b.putAt {
+		if v > start {
+			m[id] = cur[id]
+		} else {
+			delete(b.putAt, id)
+		}
+	}
+	b.byID.Store(&m)
+	return nil
+}
+
+// Run обновляет реестр раз в every до отмены ctx.
+func (b *Banners) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := b.Load(ctx); err != nil {
+				b.log.Warn("banners reload failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Get implements BannerRegistryPort (включая архивные баннеры).
+func (b *Banners) Get(id int64) (Banner, bool) {
+	bn, ok := (*b.byID.Load())[id]
+	return bn, ok
+}
+
+// Resolve implements BannerRegistryPort: баннер, в который учитывать событие id.
+func (b *Banners) Resolve(id int64) (int64, error) {
+	bn, ok := b.Get(id)
+	if ok && !bn.Archived() {
+		return id, nil
+	}
+	switch b.policy {
+	case BannerReject:
+		if ok {
+			return 0, ErrArchivedBanner
+		}
+		return 0, ErrUnknownBanner
+	case BannerQuarantine:
+		b.quarantined.Add(1)
+		return QuarantineBannerID, nil
+	default:
+		return id, nil
+	}
+}
+
+// Quarantined — число событий, учтенных в QuarantineBannerID.
+func (b *Banners) Quarantined() int64 { return b.quarantined.Load() }
+
+// List implements BannerRegistryPort: баннеры по возрастанию ID, архивные — по запросу.
+func (b *Banners) List(ctx context.Context, withArchived bool) ([]Banner, error) {
+	list, err := b.store.ListBanners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := list[:0]
+	for _, bn := range list {
+		if withArchived || !bn.Archived() {
+			out = append(out, bn)
+		}
+	}
+	return out, nil
+}
+
+// Create implements BannerRegistryPort
+func (b *Banners) Create(ctx context.Context, bn Banner) (Banner, error) {
+	if err := validateBanner(bn); err != nil {
+		return Banner{}, err
+	}
+	saved, err := b.store.CreateBanner(ctx, bn)
+	if err != nil {
+		return Banner{}, err
+	}
+	b.put(saved)
+	return saved, nil
+}
+
+// Update implements BannerRegistryPort: меняет имя и адрес перехода.
+func (b *Banners) Update(ctx context.Context, bn Banner) (Banner, error) {
+	if err := validateBanner(bn); err != nil {
+		return Banner{}, err
+	}
+	saved, err := b.store.UpdateBanner(ctx, bn)
+	if err != nil {
+		return Banner{}, err
+	}
+	b.put(saved)
+	return saved, nil
+}
+
+// Archive implements BannerRegistryPort. Повторная архивация не меняет дату.
+func (b *Banners) Archive(ctx context.Context, id int64) (Banner, error) {
+	saved, err := b.store.ArchiveBanner(ctx, id)
+	if err != nil {
+		return Banner{}, err
+	}
+	b.put(saved)
+	return saved, nil
+}
+
+// put заменяет запись в кэше копированием карты (чтения идут без блокировок).
+func (b *Banners) put(bn Banner) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cur := *b.byID.Load()
+	m := make(map[int64]Banner, len(cur)+1)
+	for id, v := range cur {
+		m[id] = v
+	}
+	m[bn.ID] = bn
+	b.byID.Store(&m)
+	b.version++
+	b.putAt[bn.ID] = b.version
+}
+
+func validateBanner(bn Banner) error {
+	if bn.ID <= 0 {
+		return fmt.Errorf("%w: id must be > 0", ErrInvalidBanner)
+	}
+	if err := ValidateTargetURL(bn.TargetURL); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBanner, err)
+	}
+	return nil
+}
+
+// ValidateTargetURL требует абсолютный http(s)-адрес; пустой адрес допустим.
+func ValidateTargetURL(s string) error {
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("target url must be absolute http(s)")
+	}
+	return nil
+}
+
+// ExpandTarget подставляет в адрес значения макросов {name} (экранированные для
+// query-строки; неизвестные макросы заменяются пустой строкой) и дописывает
+// utm-параметры, которых в адресе еще нет.
+func ExpandTarget(target string, vars map[string]string, utm url.Values) string {
+	var sb strings.Builder
+	for {
+		i := strings.IndexByte(target, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(target[i:], '}')
+		if j < 0 {
+			break
+		}
+		sb.WriteString(target[:i])
+		sb.WriteString(url.QueryEscape(vars[target[i+1:i+j]]))
+		target = target[i+j+1:]
+	}
+	sb.WriteString(target)
+	out := sb.String()
+	if len(utm) == 0 {
+		return out
+	}
+	u, err := url.Parse(out)
+	if err != nil {
+		return out
+	}
+	q := u.Query()
+	for k, v := range utm {
+		if strings.HasPrefix(k, "utm_") && !q.Has(k) && len(v) > 0 {
+			q.Set(k, v[0])
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
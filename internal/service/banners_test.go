@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestExpandTarget(t *testing.T) {
+	vars := map[string]string{"banner_id": "7", "country": "KZ", "placement": "top bar"}
+	tests := []struct {
+		name   string
+		target string
+		utm    url.Values
+		want   string
+	}{
+		{"macros", "https://ads.example/land?b={banner_id}&c={country}&p={placement}", nil,
+			"https://ads.example/land?b=7&c=KZ&p=top+bar"},
+		{"unknown macro is empty", "https://ads.example/?x={nope}", nil, "https://ads.example/?x="},
+		{"utm appended, explicit kept", "https://ads.example/?utm_source=mail",
+			url.Values{"utm_source": {"web"}, "utm_medium": {"cpc"}, "other": {"1"}},
+			"https://ads.example/?utm_medium=cpc&utm_source=mail"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ExpandTarget(tc.target, vars, tc.utm); got != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBanners_LoadDisablesInvalidTargets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockBannerStorePort(ctrl)
+	store.EXPECT().ListBanners(gomock.Any()).Return([]Banner{
+		{ID: 1, TargetURL: "https://ads.example/1"},
+		{ID: 2, TargetURL: "/relative"},
+	}, nil)
+
+	b := NewBanners(zap.NewNop(), store, BannerReject)
+	if err := b.Load(context.Background()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if bn, ok := b.Get(1); !ok || bn.TargetURL != "https://ads.example/1" {
+		t.Fatalf("expected banner 1, got %+v", bn)
+	}
+	bn, ok := b.Get(2)
+	if !ok || bn.TargetURL != "" {
+		t.Fatalf("expected banner 2 kept without redirect, got %+v (%v)", bn, ok)
+	}
+	if id, err := b.Resolve(2); err != nil || id != 2 {
+		t.Fatalf("expected banner 2 to keep counting, got %d %v", id, err)
+	}
+}
+
+func TestBanners_LoadKeepsConcurrentUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockBannerStorePort(ctrl)
+	b := NewBanners(zap.NewNop(), store, BannerAccept)
+	// пока реестр читается, баннер 1 обновляется, а баннер 2 создается
+	store.EXPECT().ListBanners(gomock.Any()).DoAndReturn(func(context.Context) ([]Banner, error) {
+		b.put(Banner{ID: 1, Name: "new"})
+		b.put(Banner{ID: 2, Name: "created"})
+		return []Banner{{ID: 1, Name: "old"}}, nil
+	})
+	if err := b.Load(context.Background()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if bn, _ := b.Get(1); bn.Name != "new" {
+		t.Fatalf("expected update to survive reload, got %+v", bn)
+	}
+	if _, ok := b.Get(2); !ok {
+		t.Fatal("expected created banner to survive reload")
+	}
+
+	// следующее чтение уже видит оба изменения и заменяет кэш
+	store.EXPECT().ListBanners(gomock.Any()).Return([]Banner{{ID: 1, Name: "newer"}}, nil)
+	if err := b.Load(context.Background()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if bn, _ := b.Get(1); bn.Name != "newer" {
+		t.Fatalf("expected reload to apply, got %+v", bn)
+	}
+	if _, ok := b.Get(2); ok {
+		t.Fatal("expected banner 2 gone after a full reload without it")
+	}
+}
+
+func TestBanners_ResolveByPolicy(t *testing.T) {
+	archived := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		policy  BannerPolicy
+		id      int64
+		want    int64
+		wantErr error
+	}{
+		{BannerReject, 1, 1, nil},
+		{BannerReject, 2, 0, ErrArchivedBanner},
+		{BannerReject, 3, 0, ErrUnknownBanner},
+		{BannerAccept, 3, 3, nil},
+		{BannerQuarantine, 2, QuarantineBannerID, nil},
+		{BannerQuarantine, 3, QuarantineBannerID, nil},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := NewMockBannerStorePort(ctrl)
+			store.EXPECT().ListBanners(gomock.Any()).Return([]Banner{
+				{ID: 1},
+				{ID: 2, ArchivedAt: &archived},
+			}, nil)
+			b := NewBanners(zap.NewNop(), store, tc.policy)
+			if err := b.Load(context.Background()); err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			got, err := b.Resolve(tc.id)
+			if !errors.Is(err, tc.wantErr) || got != tc.want {
+				t.Fatalf("Resolve(%d) = %d, %v; expected %d, %v", tc.id, got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestBanners_CreateUpdatesCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockBannerStorePort(ctrl)
+	b := NewBanners(zap.NewNop(), store, BannerReject)
+
+	if _, err := b.Create(context.Background(), Banner{ID: 4, TargetURL: "ftp://x"}); !errors.Is(err, ErrInvalidBanner) {
+		t.Fatalf("expected ErrInvalidBanner, got %v", err)
+	}
+	store.EXPECT().CreateBanner(gomock.Any(), Banner{ID: 4, Name: "promo"}).Return(Banner{ID: 4, Name: "promo"}, nil)
+	if _, err := b.Create(context.Background(), Banner{ID: 4, Name: "promo"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if id, err := b.Resolve(4); err != nil || id != 4 {
+		t.Fatalf("expected created banner to be known without reload, got %d, %v", id, err)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrBadBatch — хранилище отвергло батч из-за содержимого строк (а не недоступности);
+// такой батч делится, чтобы записать остальные строки.
+var ErrBadBatch = errors.New("batch rejected by store")
+
+// BreakerState — состояние предохранителя flush.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // flush по таймеру
+	BreakerOpen     BreakerState = "open"      // попытки приостановлены на Cooldown
+	BreakerHalfOpen BreakerState = "half_open" // пробная попытка после Cooldown
+)
+
+// FlushPolicy — повторы flush после ошибок. Нулевая политика — повтор на каждом тике.
+type FlushPolicy struct {
+	// BaseDelay и MaxDelay — экспоненциальная задержка после n-й ошибки подряд:
+	// BaseDelay*2^(n-1), не больше MaxDelay, со случайным сокращением до половины.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// BreakerThreshold ошибок подряд открывают предохранитель на BreakerCooldown (0 — без предохранителя).
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// breaker решает, когда пробовать flush, по результатам предыдущих попыток.
+type breaker struct {
+	mu       sync.Mutex
+	policy   FlushPolicy
+	failures int
+	next     time.Time
+	state    BreakerState
+	jitter   func() float64 // [0, 1)
+}
+
+func newBreaker(p FlushPolicy) *breaker {
+	return &breaker{policy: p, state: BreakerClosed, jitter: rand.Float64}
+}
+
+// allow — можно ли пробовать flush в момент now. Открытый предохранитель
+// по истечении паузы переходит в half_open.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.next) {
+		return false
+	}
+	if b.state == BreakerOpen {
+		b.state = BreakerHalfOpen
+	}
+	return true
+}
+
+// record учитывает результат попытки и возвращает паузу до следующей.
+func (b *breaker) record(now time.Time, err error) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures, b.next, b.state = 0, time.Time{}, BreakerClosed
+		return 0
+	}
+	b.failures++
+	p := b.policy
+	if p.BreakerThreshold > 0 && (b.failures >= p.BreakerThreshold || b.state == BreakerHalfOpen) {
+		b.state, b.next = BreakerOpen, now.Add(p.BreakerCooldown)
+		return p.BreakerCooldown
+	}
+	d := b.backoff()
+	b.next = now.Add(d)
+	return d
+}
+
+func (b *breaker) backoff() time.Duration {
+	p := b.policy
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < b.failures && (p.MaxDelay <= 0 || d < p.MaxDelay) && d < time.Duration(1)<<62; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d/2 + time.Duration(b.jitter()*float64(d/2))
+}
+
+func (b *breaker) current() (BreakerState, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.failures
+}
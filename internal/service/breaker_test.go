@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestBreaker_BackoffGrowsToMax(t *testing.T) {
+	b := newBreaker(FlushPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+	b.jitter = func() float64 { return 1 } // без сокращения задержки
+	now := time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)
+	fail := errors.New("db down")
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := b.record(now, fail); got != want {
+			t.Fatalf("failure %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+	if b.allow(now.Add(4 * time.Second)) {
+		t.Fatal("expected attempt to wait for the backoff")
+	}
+	if !b.allow(now.Add(5 * time.Second)) {
+		t.Fatal("expected attempt after the backoff")
+	}
+	if state, failures := b.current(); state != BreakerClosed || failures != 5 {
+		t.Fatalf("expected closed breaker without threshold, got %s after %d failures", state, failures)
+	}
+}
+
+func TestBreaker_OpenHalfOpenClosed(t *testing.T) {
+	b := newBreaker(FlushPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, BreakerThreshold: 2, BreakerCooldown: 30 * time.Second})
+	b.jitter = func() float64 { return 0 }
+	now := time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)
+	fail := errors.New("db down")
+
+	if d := b.record(now, fail); d != 500*time.Millisecond {
+		t.Fatalf("expected half of the base delay, got %v", d)
+	}
+	if d := b.record(now, fail); d != 30*time.Second {
+		t.Fatalf("expected cooldown after threshold, got %v", d)
+	}
+	if state, _ := b.current(); state != BreakerOpen {
+		t.Fatalf("expected open, got %s", state)
+	}
+	if b.allow(now.Add(29 * time.Second)) {
+		t.Fatal("expected open breaker to block attempts")
+	}
+
+	// пробная попытка после паузы; ошибка снова открывает предохранитель
+	if !b.allow(now.Add(30 * time.Second)) {
+		t.Fatal("expected a trial attempt after cooldown")
+	}
+	if state, _ := b.current(); state != BreakerHalfOpen {
+		t.Fatalf("expected half_open, got %s", state)
+	}
+	now = now.Add(30 * time.Second)
+	if d := b.record(now, fail); d != 30*time.Second {
+		t.Fatalf("expected failed trial to reopen, got %v", d)
+	}
+
+	if !b.allow(now.Add(30 * time.Second)) {
+		t.Fatal("expected a trial attempt after cooldown")
+	}
+	b.record(now.Add(30*time.Second), nil)
+	if state, failures := b.current(); state != BreakerClosed || failures != 0 {
+		t.Fatalf("expected closed after success, got %s with %d failures", state, failures)
+	}
+	if !b.allow(now.Add(30 * time.Second)) {
+		t.Fatal("expected closed breaker to allow attempts")
+	}
+}
+
+func TestAggregator_Flush_SplitsBadBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t0 := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	w := NewMockAggregateWriter(ctrl)
+	var written []AggregateRow
+	w.EXPECT().UpsertAggregates(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, rows []AggregateRow) error {
+		for _, r := range rows {
+			if r.BannerID == 3 {
+				return ErrBadBatch
+			}
+		}
+		written = append(written, rows...)
+		return nil
+	}).AnyTimes()
+
+	agg := NewAggregator(zap.NewNop(), w, 4, time.Hour)
+	for id := int64(1); id <= 5; id++ {
+		_ = agg.Inc(id, t0)
+	}
+	if err := agg.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(written) != 4 {
+		t.Fatalf("expected 4 rows written around the bad one, got %v", written)
+	}
+	for _, r := range written {
+		if r.BannerID == 3 {
+			t.Fatalf("bad row written: %v", written)
+		}
+	}
+	if agg.Poisoned() != 1 {
+		t.Fatalf("expected 1 poisoned row, got %d", agg.Poisoned())
+	}
+	if keys := agg.PendingKeys(); keys[0]+keys[1]+keys[2]+keys[3] != 0 {
+		t.Fatalf("expected nothing pending, got %v", keys)
+	}
+}
+
+func TestAggregator_Flush_KeepsRowsOnStoreError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t0 := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	w := NewMockAggregateWriter(ctrl)
+	w.EXPECT().UpsertAggregates(gomock.Any(), gomock.Any()).Return(errors.New("db down"))
+
+	agg := NewAggregator(zap.NewNop(), w, 1, time.Hour)
+	_ = agg.Inc(1, t0)
+	_ = agg.Inc(2, t0)
+	if err := agg.flush(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if keys := agg.PendingKeys(); keys[0] != 2 || agg.Poisoned() != 0 {
+		t.Fatalf("expected rows kept for retry, got %v pending, %d poisoned", keys, agg.Poisoned())
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+)
+
+var (
+	ErrUnknownCampaign = errors.New("unknown campaign")
+	ErrCampaignExists  = errors.New("campaign already exists")
+	ErrInvalidCampaign = errors.New("invalid campaign")
+	ErrAlreadyMember   = errors.New("banner is already in campaign")
+	ErrNotMember       = errors.New("banner is not in campaign")
+)
+
+// Campaign — группа баннеров (кампания рекламодателя).
+type Campaign struct {
+	ID         int64
+	Name       string
+	Advertiser string
+	CreatedAt  time.Time
+	Members    []Membership // история членства, заполняется при чтении одной кампании
+}
+
+// Membership — баннер входит в кампанию на интервале [From, To); To == nil — по сей день.
+type Membership struct {
+	CampaignID int64
+	BannerID   int64
+	From       time.Time
+	To         *time.Time
+}
+
+// Campaigns — кампании и статистика по ним с учетом истории членства баннеров.
+type Campaigns struct {
+	store CampaignStorePort
+	stats *Stats
+}
+
+func NewCampaigns(store CampaignStorePort, stats *Stats) *Campaigns {
+	return &Campaigns{store: store, stats: stats}
+}
+
+// Create implements CampaignPort
+func (c *Campaigns) Create(ctx context.Context, cp Campaign) (Campaign, error) {
+	if cp.ID <= 0 {
+		return Campaign{}, fmt.Errorf("%w: id must be > 0", ErrInvalidCampaign)
+	}
+	return c.store.CreateCampaign(ctx, cp)
+}
+
+// List implements CampaignPort
+func (c *Campaigns) List(ctx context.Context) ([]Campaign, error) {
+	return c.store.ListCampaigns(ctx)
+}
+
+// Get implements CampaignPort
+func (c *Campaigns) Get(ctx context.Context, id int64) (Campaign, error) {
+	return c.store.GetCampaign(ctx, id)
+}
+
+// AddBanner implements CampaignPort: баннер входит в кампанию начиная с from (нулевое — сейчас).
+func (c *Campaigns) AddBanner(ctx context.Context, campaignID, bannerID int64, from time.Time) (Membership, error) {
+	if bannerID <= 0 {
+		return Membership{}, fmt.Errorf("%w: banner id must be > 0", ErrInvalidCampaign)
+	}
+	if from.IsZero() {
+		from = time.Now()
+	}
+	return c.store.AddCampaignBanner(ctx, Membership{CampaignID: campaignID, BannerID: bannerID, From: minuteUTC(from)})
+}
+
+// RemoveBanner implements CampaignPort: текущее членство баннера заканчивается в at (нулевое — сейчас).
+func (c *Campaigns) RemoveBanner(ctx context.Context, campaignID, bannerID int64, at time.Time) (Membership, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return c.store.EndCampaignBanner(ctx, campaignID, bannerID, minuteUTC(at))
+}
+
+// Query implements CampaignPort: сумма рядов баннеров кампании за [q.From, q.To),
+// каждый баннер — только за время, пока он входил в кампанию.
+func (c *Campaigns) Query(ctx context.Context, campaignID int64, q StatsQuery) (*entity.StatsResponse, error) {
+	return withComparison(q, func(q StatsQuery) (*entity.StatsResponse, error) {
+		b := q.buckets()
+		members, err := c.store.CampaignMembers(ctx, campaignID, b.Truncate(q.From), b.Ceil(q.To))
+		if err != nil {
+			return nil, err
+		}
+		return c.stats.QuerySpans(ctx, q, spansOf(members))
+	})
+}
+
+// spansOf объединяет пересекающиеся и смежные интервалы членства одного баннера,
+// чтобы его клики не учитывались дважды.
+func spansOf(members []Membership) []Span {
+	sorted := append([]Membership(nil), members...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].BannerID != sorted[j].BannerID {
+			return sorted[i].BannerID < sorted[j].BannerID
+		}
+		return sorted[i].From.Before(sorted[j].From)
+	})
+	var out []Span
+	for _, m := range sorted {
+		var to time.Time
+		if m.To != nil {
+			to = *m.To
+		}
+		if n := len(out); n > 0 && out[n-1].BannerID == m.BannerID {
+			last := &out[n-1]
+			if last.To.IsZero() || !m.From.After(last.To) {
+				if !last.To.IsZero() && (to.IsZero() || to.After(last.To)) {
+					last.To = to
+				}
+				continue
+			}
+		}
+		out = append(out, Span{BannerID: m.BannerID, From: m.From, To: to})
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/golang/mock/gomock"
+)
+
+func TestSpansOf_MergesOverlappingMemberships(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2025, 10, 1, h, 0, 0, 0, time.UTC) }
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	got := spansOf([]Membership{
+		{BannerID: 2, From: at(5)},
+		{BannerID: 1, From: at(3), To: ptr(at(6))},
+		{BannerID: 1, From: at(1), To: ptr(at(4))},
+		{BannerID: 1, From: at(8), To: ptr(at(9))},
+		{BannerID: 2, From: at(1), To: ptr(at(5))},
+	})
+	want := []Span{
+		{BannerID: 1, From: at(1), To: at(6)},
+		{BannerID: 1, From: at(8), To: at(9)},
+		{BannerID: 2, From: at(1)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("span %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestCampaigns_Query_SumsMembersWithinMembership(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockCampaignStorePort(ctrl)
+	reader := NewMockStatsReaderPort(ctrl)
+	campaigns := NewCampaigns(store, NewStats(reader, nil, nil, 90, nil, 0))
+
+	day := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	from, to := day, day.Add(4*time.Hour)
+	left := day.Add(90 * time.Minute)
+
+	// Баннер 1 — участник весь период, баннер 2 ушел из кампании в 01:30
+	store.EXPECT().CampaignMembers(gomock.Any(), int64(7), from, to).Return([]Membership{
+		{CampaignID: 7, BannerID: 1, From: day.AddDate(0, 0, -1)},
+		{CampaignID: 7, BannerID: 2, From: day.AddDate(0, 0, -1), To: &left},
+	}, nil)
+	reader.EXPECT().
+		QueryRange(gomock.Any(), RangeQuery{BannerID: 1, From: from, To: to, Resolution: ResolutionHour}).
+		Return([]AggregateRow{{BannerID: 1, TS: day, Cnt: 1}, {BannerID: 1, TS: day.Add(2 * time.Hour), Cnt: 2}}, nil)
+	reader.EXPECT().
+		QueryRange(gomock.Any(), RangeQuery{BannerID: 2, From: from, To: left, Resolution: ResolutionMinute}).
+		Return([]AggregateRow{{BannerID: 2, TS: day, Cnt: 5}, {BannerID: 2, TS: day.Add(time.Hour), Cnt: 3}}, nil)
+
+	resp, err := campaigns.Query(context.Background(), 7, StatsQuery{From: from, To: to, Granularity: GranularityHour})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []entity.Point{{TS: day, V: 6}, {TS: day.Add(time.Hour), V: 3}, {TS: day.Add(2 * time.Hour), V: 2}}
+	if len(resp.Stats) != len(want) {
+		t.Fatalf("expected %v, got %v", want, resp.Stats)
+	}
+	for i := range want {
+		if resp.Stats[i] != want[i] {
+			t.Fatalf("point %d: expected %v, got %v", i, want[i], resp.Stats[i])
+		}
+	}
+}
+
+func TestCampaigns_Query_UnknownCampaign(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockCampaignStorePort(ctrl)
+	campaigns := NewCampaigns(store, NewStats(NewMockStatsReaderPort(ctrl), nil, nil, 90, nil, 0))
+	store.EXPECT().CampaignMembers(gomock.Any(), int64(7), gomock.Any(), gomock.Any()).Return(nil, ErrUnknownCampaign)
+
+	from := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := campaigns.Query(context.Background(), 7, StatsQuery{From: from, To: from.Add(time.Hour)}); !errors.Is(err, ErrUnknownCampaign) {
+		t.Fatalf("expected ErrUnknownCampaign, got %v", err)
+	}
+}
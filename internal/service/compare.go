@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+)
+
+var ErrUnknownCompare = errors.New("unknown compare")
+
+// CompareMode — с каким диапазоном сравнивать ряд.
+type CompareMode string
+
+const (
+	ComparePreviousPeriod CompareMode = "previous_period" // такой же длины, сразу перед диапазоном
+	ComparePreviousWeek   CompareMode = "previous_week"   // на 7 дней раньше
+	ComparePreviousYear   CompareMode = "previous_year"   // на год раньше (по календарю)
+	CompareOffset         CompareMode = "offset"          // на Offset раньше
+)
+
+// Compare — сравнение с предыдущим диапазоном; нулевое значение — без сравнения.
+type Compare struct {
+	Mode   CompareMode
+	Offset time.Duration
+}
+
+// ParseCompare принимает имя режима или смещение назад: длительность Go ("36h")
+// либо число дней или недель ("7d", "2w").
+func ParseCompare(s string) (Compare, error) {
+	switch m := CompareMode(s); m {
+	case "":
+		return Compare{}, nil
+	case ComparePreviousPeriod, ComparePreviousWeek, ComparePreviousYear:
+		return Compare{Mode: m}, nil
+	}
+	d, err := parseOffset(s)
+	if err != nil || d <= 0 {
+		return Compare{}, ErrUnknownCompare
+	}
+	return Compare{Mode: CompareOffset, Offset: d}, nil
+}
+
+func parseOffset(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			return time.Duration(v) * unit, err
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// shift возвращает диапазон для сравнения с [from, to).
+func (c Compare) shift(from, to time.Time) (time.Time, time.Time) {
+	switch c.Mode {
+	case ComparePreviousPeriod:
+		return from.Add(-to.Sub(from)), from
+	case ComparePreviousWeek:
+		return from.AddDate(0, 0, -7), to.AddDate(0, 0, -7)
+	case ComparePreviousYear:
+		return from.AddDate(-1, 0, 0), to.AddDate(-1, 0, 0)
+	default:
+		return from.Add(-c.Offset), to.Add(-c.Offset)
+	}
+}
+
+// previous — запрос того же ряда за диапазон сравнения (без групп, уникальных и сводки).
+func (q StatsQuery) previous() StatsQuery {
+	b := q.buckets()
+	p := q
+	p.From, p.To = q.Compare.shift(b.Truncate(q.From), b.Ceil(q.To))
+	p.Compare, p.GroupBy, p.Uniques, p.Summary = Compare{}, nil, false, false
+	return p
+}
+
+// withComparison выполняет запрос и, если задан q.Compare, тот же запрос за
+// диапазон сравнения, добавляя к ответу сопоставленные ряды.
+func withComparison(q StatsQuery, run func(StatsQuery) (*entity.StatsResponse, error)) (*entity.StatsResponse, error) {
+	resp, err := run(q)
+	if err != nil || q.Compare.Mode == "" {
+		return resp, err
+	}
+	pq := q.previous()
+	prev, err := run(pq)
+	if err != nil {
+		return nil, err
+	}
+	resp.Compare = comparison(q, pq, resp.Stats, prev.Stats)
+	return resp, nil
+}
+
+// comparison сопоставляет бакеты текущего и предыдущего диапазонов по номеру от начала
+// (i-й бакет с i-м); бакеты без данных считаются нулевыми.
+func comparison(q, pq StatsQuery, cur, prev []entity.Point) *entity.Comparison {
+	b := q.buckets()
+	curV, prevV := clicksByTS(cur), clicksByTS(prev)
+	out := &entity.Comparison{From: pq.From, To: pq.To}
+	var curTotal, prevTotal int64
+	ts, pts := b.Truncate(q.From), b.Truncate(pq.From)
+	for i, to := 0, b.Ceil(q.To); ts.Before(to); i++ {
+		var p int64
+		if pts.Before(pq.To) {
+			p = prevV[pts]
+		}
+		out.Points = append(out.Points, entity.ComparePoint{Offset: i, TS: ts, PrevTS: pts, Delta: delta(curV[ts], p)})
+		curTotal += curV[ts]
+		ts, pts = b.Next(ts), b.Next(pts)
+	}
+	for _, v := range prevV {
+		prevTotal += v
+	}
+	out.Total = delta(curTotal, prevTotal)
+	return out
+}
+
+func clicksByTS(pts []entity.Point) map[time.Time]int64 {
+	m := make(map[time.Time]int64, len(pts))
+	for _, p := range pts {
+		if !p.Null {
+			m[p.TS] = p.V
+		}
+	}
+	return m
+}
+
+// delta — изменение cur относительно prev; процент не считается при prev = 0.
+func delta(cur, prev int64) entity.Delta {
+	d := entity.Delta{Current: cur, Previous: prev, Delta: cur - prev}
+	if prev != 0 {
+		pct := float64(cur-prev) / float64(prev) * 100
+		d.DeltaPct = &pct
+	}
+	return d
+}
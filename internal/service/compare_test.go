@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestParseCompare(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Compare
+		err  error
+	}{
+		{"", Compare{}, nil},
+		{"previous_week", Compare{Mode: ComparePreviousWeek}, nil},
+		{"36h", Compare{Mode: CompareOffset, Offset: 36 * time.Hour}, nil},
+		{"2w", Compare{Mode: CompareOffset, Offset: 14 * 24 * time.Hour}, nil},
+		{"-1h", Compare{}, ErrUnknownCompare},
+		{"last_month", Compare{}, ErrUnknownCompare},
+	}
+	for _, tc := range tests {
+		got, err := ParseCompare(tc.in)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Fatalf("%q: expected %v (%v), got %v (%v)", tc.in, tc.want, tc.err, got, err)
+		}
+	}
+}
+
+func TestStats_Query_ComparePreviousPeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	stats := NewStats(reader, nil, NewDimensions(map[string]int{"country": 10}), 90, nil, 0)
+
+	from := time.Date(2025, 10, 13, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+	prevFrom := from.AddDate(0, 0, -2)
+
+	reader.EXPECT().
+		QueryRange(gomock.Any(), RangeQuery{BannerID: 1, From: from, To: to, Resolution: ResolutionDay, GroupBy: []string{"country"}}).
+		Return([]AggregateRow{{BannerID: 1, TS: from, Cnt: 15}, {BannerID: 1, TS: from.AddDate(0, 0, 1), Cnt: 4}}, nil)
+	reader.EXPECT().
+		QueryRange(gomock.Any(), RangeQuery{BannerID: 1, From: prevFrom, To: from, Resolution: ResolutionDay}).
+		Return([]AggregateRow{{BannerID: 1, TS: prevFrom, Cnt: 10}}, nil)
+
+	resp, err := stats.Query(context.Background(), StatsQuery{
+		BannerID: 1, From: from, To: to, Granularity: GranularityDay, GroupBy: []string{"country"},
+		Compare: Compare{Mode: ComparePreviousPeriod},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := resp.Compare
+	if c == nil || !c.From.Equal(prevFrom) || !c.To.Equal(from) || len(c.Points) != 2 {
+		t.Fatalf("unexpected comparison %+v", c)
+	}
+	p0, p1 := c.Points[0], c.Points[1]
+	if p0.Offset != 0 || !p0.PrevTS.Equal(prevFrom) || p0.Current != 15 || p0.Previous != 10 || p0.Delta.Delta != 5 || *p0.DeltaPct != 50 {
+		t.Fatalf("unexpected first point %+v", p0)
+	}
+	if p1.Current != 4 || p1.Previous != 0 || p1.DeltaPct != nil {
+		t.Fatalf("unexpected second point %+v", p1)
+	}
+	if c.Total.Current != 19 || c.Total.Previous != 10 || c.Total.Delta != 9 {
+		t.Fatalf("unexpected total %+v", c.Total)
+	}
+}
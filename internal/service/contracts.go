@@ -8,24 +8,227 @@ import (
 )
 //go:generate mockgen -source=contracts.go -destination=./contracts_mock.go -package=service
 
+// AggregatorPort — учет событий. Inc и Add возвращают ErrOverloaded, если событие
+// не принято из-за лимита несохраненных данных.
 type AggregatorPort interface {
-	Inc(bannerID int64, now time.Time)
+	Inc(bannerID int64, now time.Time) error
+	Add(ev Event) error
 	Run(ctx context.Context)
 	Stop(ctx context.Context)
 }
 
+// StatsPort — сценарий чтения статистики для транспорта.
+type StatsPort interface {
+	Query(ctx context.Context, q StatsQuery) (*entity.StatsResponse, error)
+	// QueryMulti — ряды нескольких баннеров (q.BannerID не используется).
+	QueryMulti(ctx context.Context, q StatsQuery, ids []int64) (map[int64]*entity.StatsResponse, error)
+	Top(ctx context.Context, q TopQuery) (*entity.TopResponse, error)
+	// Export передает в fn строки всех баннеров за диапазон; ошибка fn прерывает выгрузку.
+	Export(ctx context.Context, q ExportQuery, fn func(AggregateRow) error) error
+}
+
+// StatsReaderPort — чтение агрегатов за [q.From, q.To). Строки возвращаются
+// отсортированными по времени, Dims в них — проекция на q.GroupBy.
 type StatsReaderPort interface {
-	QueryRange(ctx context.Context, bannerID int64, from, to time.Time) ([]entity.Point, error)
+	QueryRange(ctx context.Context, q RangeQuery) ([]AggregateRow, error)
+	// QueryUniques возвращает HLL-скетчи посетителей баннеров за [q.From, q.To)
+	// (Filter и GroupBy не применяются).
+	QueryUniques(ctx context.Context, q RangeQuery) ([]SketchRow, error)
+	// QueryTop возвращает суммы кликов первых q.Limit баннеров за [q.From, q.To)
+	// и баннеров из q.Include (в любом порядке).
+	QueryTop(ctx context.Context, q TopQuery) ([]BannerTotal, error)
+	// ExportRange передает в fn строки всех баннеров таблицы res за [from, to)
+	// по мере чтения, в порядке (ts, banner_id), с полными измерениями.
+	ExportRange(ctx context.Context, res Resolution, from, to time.Time, fn func(AggregateRow) error) error
+}
+
+// RangeQuery — запрос к хранилищу агрегатов. Resolution — самая грубая допустимая
+// для вызывающего резолюция: хранилище может вернуть строки мельче, но не крупнее.
+type RangeQuery struct {
+	BannerID   int64
+	BannerIDs  []int64 // если не пусто — запрос по этим баннерам вместо BannerID
+	From, To   time.Time
+	Resolution Resolution
+	Filter     Dims     // только строки с этими значениями измерений
+	GroupBy    []string // разбиение по измерениям; пусто — суммы по всем
+}
+
+// Banners — баннеры запроса.
+func (q RangeQuery) Banners() []int64 {
+	if len(q.BannerIDs) > 0 {
+		return q.BannerIDs
+	}
+	return []int64{q.BannerID}
+}
+
+// PendingReaderPort — чтение еще не записанных в БД инкрементов.
+// FlushGen — счетчик-seqlock: нечетный во время записи батча, меняется при каждом flush.
+// Чтение БД между двумя одинаковыми четными значениями согласовано с Pending.
+type PendingReaderPort interface {
+	FlushGen() uint64
+	// Pending — несохраненные строки баннеров ids за [from, to) и FlushGen.
+	Pending(ids []int64, from, to time.Time) ([]AggregateRow, uint64)
+	// PendingSketches — несохраненные скетчи баннеров ids, включая записываемые в данный момент
+	// (слияние скетчей идемпотентно, поэтому согласование с FlushGen не нужно).
+	PendingSketches(ids []int64, from, to time.Time) []SketchRow
+	// PendingTotals — несохраненные клики всех баннеров за [from, to) и FlushGen.
+	PendingTotals(from, to time.Time) (map[int64]int64, uint64)
+}
+
+// BannerRegistryPort — реестр баннеров. Get и Resolve работают по кэшу в памяти
+// и вызываются на каждом событии; остальные методы — для управления реестром.
+type BannerRegistryPort interface {
+	Get(id int64) (Banner, bool)
+	// Resolve применяет политику к неизвестным и архивным баннерам: возвращает баннер,
+	// в который учитывать событие, или ErrUnknownBanner/ErrArchivedBanner.
+	Resolve(id int64) (int64, error)
+	List(ctx context.Context, withArchived bool) ([]Banner, error)
+	Create(ctx context.Context, b Banner) (Banner, error)
+	Update(ctx context.Context, b Banner) (Banner, error)
+	Archive(ctx context.Context, id int64) (Banner, error)
+}
+
+// BannerStorePort — хранилище реестра баннеров. Create возвращает ErrBannerExists,
+// Update и Archive — ErrUnknownBanner.
+type BannerStorePort interface {
+	ListBanners(ctx context.Context) ([]Banner, error)
+	CreateBanner(ctx context.Context, b Banner) (Banner, error)
+	UpdateBanner(ctx context.Context, b Banner) (Banner, error)
+	ArchiveBanner(ctx context.Context, id int64) (Banner, error)
+}
+
+// CampaignPort — кампании (группы баннеров) и их статистика.
+type CampaignPort interface {
+	Create(ctx context.Context, c Campaign) (Campaign, error)
+	List(ctx context.Context) ([]Campaign, error)
+	Get(ctx context.Context, id int64) (Campaign, error)
+	AddBanner(ctx context.Context, campaignID, bannerID int64, from time.Time) (Membership, error)
+	RemoveBanner(ctx context.Context, campaignID, bannerID int64, at time.Time) (Membership, error)
+	Query(ctx context.Context, campaignID int64, q StatsQuery) (*entity.StatsResponse, error)
+}
+
+// CampaignStorePort — хранилище кампаний и истории членства баннеров.
+// Методы возвращают ErrUnknownCampaign для несуществующей кампании.
+type CampaignStorePort interface {
+	CreateCampaign(ctx context.Context, c Campaign) (Campaign, error)
+	ListCampaigns(ctx context.Context) ([]Campaign, error)
+	GetCampaign(ctx context.Context, id int64) (Campaign, error)
+	// AddCampaignBanner возвращает ErrAlreadyMember, если у баннера есть незавершенное членство.
+	AddCampaignBanner(ctx context.Context, m Membership) (Membership, error)
+	// EndCampaignBanner завершает незавершенное членство; ErrNotMember, если его нет.
+	EndCampaignBanner(ctx context.Context, campaignID, bannerID int64, at time.Time) (Membership, error)
+	// CampaignMembers — членства, пересекающиеся с [from, to).
+	CampaignMembers(ctx context.Context, campaignID int64, from, to time.Time) ([]Membership, error)
+}
+
+// HealthPort — проверка готовности принимать трафик; Readiness описывает каждую проверку.
+type HealthPort interface {
+	Ready(ctx context.Context) (entity.Readiness, bool)
+}
+
+// PingerPort — проверка соединения с хранилищем.
+type PingerPort interface {
+	Ping(ctx context.Context) error
+}
+
+// FlushStatusPort — состояние записи агрегатора: время последнего успешного flush,
+// число несохраненных ключей по шардам и состояние предохранителя flush.
+type FlushStatusPort interface {
+	LastFlush() time.Time
+	PendingKeys() []int
+	BreakerState() (BreakerState, int)
+}
+
+// Resolution — шаг, с которым хранятся агрегаты (минутные, часовые и дневные роллапы).
+type Resolution int
+
+const (
+	ResolutionMinute Resolution = iota
+	ResolutionHour
+	ResolutionDay
+)
+
+func (r Resolution) Step() time.Duration {
+	switch r {
+	case ResolutionHour:
+		return time.Hour
+	case ResolutionDay:
+		return 24 * time.Hour
+	default:
+		return time.Minute
+	}
 }
 
 // AggregateWriter — порт для записи агрегированных значений в БД.
 type AggregateWriter interface {
 	UpsertAggregates(ctx context.Context, rows []AggregateRow) error
+	// MergeSketches сливает скетчи с уже сохраненными; повторная запись безопасна.
+	MergeSketches(ctx context.Context, rows []SketchRow) error
+}
+
+// FlushObserver — наблюдатель за flush агрегатора: rows — строк в батче, err — итог записи.
+type FlushObserver interface {
+	ObserveFlush(rows int, took time.Duration, err error)
+}
+
+// FlushTracer — трассировка flush агрегатора: StartFlush открывает спан, записи в хранилище
+// идут с возвращенным ctx (их спаны — дочерние), end закрывает спан с итогом flush.
+type FlushTracer interface {
+	StartFlush(ctx context.Context) (_ context.Context, end func(rows int, err error))
+}
+
+// Journal — порт write-ahead журнала инкрементов.
+// Записи пишутся сегментами: Rotate открывает новый сегмент и возвращает его номер,
+// Commit удаляет все сегменты до этого номера (их содержимое уже записано в БД).
+// Append возвращает номер сегмента, в который попала запись; Sync делает записи устойчивыми.
+type Journal interface {
+	Append(ev Event) (uint64, error)
+	Sync() error
+	Rotate() (uint64, error)
+	Commit(checkpoint uint64) error
+	Replay(fn func(ev Event)) error
 }
 
+// SpillLog — порт журнала событий сверх лимита ключей (OverflowSpill). ReplayOldest читает
+// самые старые сегменты целиком, пока не прочитано limit записей (0 — все), и возвращает
+// номер сегмента для Commit (0 — читать было нечего).
+type SpillLog interface {
+	Append(ev Event) (uint64, error)
+	Rotate() (uint64, error)
+	ReplayOldest(limit int, fn func(ev Event)) (uint64, error)
+	Commit(checkpoint uint64) error
+}
+
+// Event — клики (или показы) одного баннера с одинаковыми временем и измерениями.
+type Event struct {
+	BannerID int64
+	TS       time.Time
+	Count    int64
+	Dims     Dims
+	Visitor  uint64 // хэш идентификатора посетителя, 0 — неизвестен
+	Kind     EventKind
+}
+
+// EventKind — что именно считает событие.
+type EventKind uint8
+
+const (
+	KindClick EventKind = iota
+	KindImpression
+)
+
 // AggregateRow — одна строка агрегата (поминутная).
 type AggregateRow struct {
 	BannerID int64
 	TS       time.Time // начало минуты (UTC)
-	Cnt      int64
+	Dims     Dims
+	Cnt      int64 // клики
+	Imps     int64 // показы
+}
+
+// SketchRow — HyperLogLog-скетч посетителей баннера за минуту (или бакет роллапа).
+type SketchRow struct {
+	BannerID int64
+	TS       time.Time
+	Sketch   []byte
 }
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: contracts.go
+// Source: internal/service/contracts.go
 
 // Package service is a generated GoMock package.
 package service
@@ -36,10 +36,26 @@ func (m *MockAggregatorPort) EXPECT() *MockAggregatorPortMockRecorder {
 	return m.recorder
 }
 
+// Add mocks base method.
+func (m *MockAggregatorPort) Add(ev Event) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Add", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Add indicates an expected call of Add.
+func (mr *MockAggregatorPortMockRecorder) Add(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockAggregatorPort)(nil).Add), ev)
+}
+
 // Inc mocks base method.
-func (m *MockAggregatorPort) Inc(bannerID int64, now time.Time) {
+func (m *MockAggregatorPort) Inc(bannerID int64, now time.Time) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Inc", bannerID, now)
+	ret := m.ctrl.Call(m, "Inc", bannerID, now)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // Inc indicates an expected call of Inc.
@@ -72,6 +88,88 @@ func (mr *MockAggregatorPortMockRecorder) Stop(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockAggregatorPort)(nil).Stop), ctx)
 }
 
+// MockStatsPort is a mock of StatsPort interface.
+type MockStatsPort struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatsPortMockRecorder
+}
+
+// MockStatsPortMockRecorder is the mock recorder for MockStatsPort.
+type MockStatsPortMockRecorder struct {
+	mock *MockStatsPort
+}
+
+// NewMockStatsPort creates a new mock instance.
+func NewMockStatsPort(ctrl *gomock.Controller) *MockStatsPort {
+	mock := &MockStatsPort{ctrl: ctrl}
+	mock.recorder = &MockStatsPortMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatsPort) EXPECT() *MockStatsPortMockRecorder {
+	return m.recorder
+}
+
+// Export mocks base method.
+func (m *MockStatsPort) Export(ctx context.Context, q ExportQuery, fn func(AggregateRow) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx, q, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockStatsPortMockRecorder) Export(ctx, q, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockStatsPort)(nil).Export), ctx, q, fn)
+}
+
+// Query mocks base method.
+func (m *MockStatsPort) Query(ctx context.Context, q StatsQuery) (*entity.StatsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", ctx, q)
+	ret0, _ := ret[0].(*entity.StatsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Query indicates an expected call of Query.
+func (mr *MockStatsPortMockRecorder) Query(ctx, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockStatsPort)(nil).Query), ctx, q)
+}
+
+// QueryMulti mocks base method.
+func (m *MockStatsPort) QueryMulti(ctx context.Context, q StatsQuery, ids []int64) (map[int64]*entity.StatsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryMulti", ctx, q, ids)
+	ret0, _ := ret[0].(map[int64]*entity.StatsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryMulti indicates an expected call of QueryMulti.
+func (mr *MockStatsPortMockRecorder) QueryMulti(ctx, q, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryMulti", reflect.TypeOf((*MockStatsPort)(nil).QueryMulti), ctx, q, ids)
+}
+
+// Top mocks base method.
+func (m *MockStatsPort) Top(ctx context.Context, q TopQuery) (*entity.TopResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Top", ctx, q)
+	ret0, _ := ret[0].(*entity.TopResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Top indicates an expected call of Top.
+func (mr *MockStatsPortMockRecorder) Top(ctx, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Top", reflect.TypeOf((*MockStatsPort)(nil).Top), ctx, q)
+}
+
 // MockStatsReaderPort is a mock of StatsReaderPort interface.
 type MockStatsReaderPort struct {
 	ctrl     *gomock.Controller
@@ -95,54 +193,1006 @@ func (m *MockStatsReaderPort) EXPECT() *MockStatsReaderPortMockRecorder {
 	return m.recorder
 }
 
+// ExportRange mocks base method.
+func (m *MockStatsReaderPort) ExportRange(ctx context.Context, res Resolution, from, to time.Time, fn func(AggregateRow) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportRange", ctx, res, from, to, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportRange indicates an expected call of ExportRange.
+func (mr *MockStatsReaderPortMockRecorder) ExportRange(ctx, res, from, to, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportRange", reflect.TypeOf((*MockStatsReaderPort)(nil).ExportRange), ctx, res, from, to, fn)
+}
+
 // QueryRange mocks base method.
-func (m *MockStatsReaderPort) QueryRange(ctx context.Context, bannerID int64, from, to time.Time) ([]entity.Point, error) {
+func (m *MockStatsReaderPort) QueryRange(ctx context.Context, q RangeQuery) ([]AggregateRow, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "QueryRange", ctx, bannerID, from, to)
-	ret0, _ := ret[0].([]entity.Point)
+	ret := m.ctrl.Call(m, "QueryRange", ctx, q)
+	ret0, _ := ret[0].([]AggregateRow)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // QueryRange indicates an expected call of QueryRange.
-func (mr *MockStatsReaderPortMockRecorder) QueryRange(ctx, bannerID, from, to interface{}) *gomock.Call {
+func (mr *MockStatsReaderPortMockRecorder) QueryRange(ctx, q interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRange", reflect.TypeOf((*MockStatsReaderPort)(nil).QueryRange), ctx, bannerID, from, to)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRange", reflect.TypeOf((*MockStatsReaderPort)(nil).QueryRange), ctx, q)
 }
 
-// MockAggregateWriter is a mock of AggregateWriter interface.
-type MockAggregateWriter struct {
+// QueryTop mocks base method.
+func (m *MockStatsReaderPort) QueryTop(ctx context.Context, q TopQuery) ([]BannerTotal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryTop", ctx, q)
+	ret0, _ := ret[0].([]BannerTotal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryTop indicates an expected call of QueryTop.
+func (mr *MockStatsReaderPortMockRecorder) QueryTop(ctx, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryTop", reflect.TypeOf((*MockStatsReaderPort)(nil).QueryTop), ctx, q)
+}
+
+// QueryUniques mocks base method.
+func (m *MockStatsReaderPort) QueryUniques(ctx context.Context, q RangeQuery) ([]SketchRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryUniques", ctx, q)
+	ret0, _ := ret[0].([]SketchRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryUniques indicates an expected call of QueryUniques.
+func (mr *MockStatsReaderPortMockRecorder) QueryUniques(ctx, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryUniques", reflect.TypeOf((*MockStatsReaderPort)(nil).QueryUniques), ctx, q)
+}
+
+// MockPendingReaderPort is a mock of PendingReaderPort interface.
+type MockPendingReaderPort struct {
 	ctrl     *gomock.Controller
-	recorder *MockAggregateWriterMockRecorder
+	recorder *MockPendingReaderPortMockRecorder
 }
 
-// MockAggregateWriterMockRecorder is the mock recorder for MockAggregateWriter.
-type MockAggregateWriterMockRecorder struct {
-	mock *MockAggregateWriter
+// MockPendingReaderPortMockRecorder is the mock recorder for MockPendingReaderPort.
+type MockPendingReaderPortMockRecorder struct {
+	mock *MockPendingReaderPort
 }
 
-// NewMockAggregateWriter creates a new mock instance.
-func NewMockAggregateWriter(ctrl *gomock.Controller) *MockAggregateWriter {
-	mock := &MockAggregateWriter{ctrl: ctrl}
-	mock.recorder = &MockAggregateWriterMockRecorder{mock}
+// NewMockPendingReaderPort creates a new mock instance.
+func NewMockPendingReaderPort(ctrl *gomock.Controller) *MockPendingReaderPort {
+	mock := &MockPendingReaderPort{ctrl: ctrl}
+	mock.recorder = &MockPendingReaderPortMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockAggregateWriter) EXPECT() *MockAggregateWriterMockRecorder {
+func (m *MockPendingReaderPort) EXPECT() *MockPendingReaderPortMockRecorder {
 	return m.recorder
 }
 
-// UpsertAggregates mocks base method.
-func (m *MockAggregateWriter) UpsertAggregates(ctx context.Context, rows []AggregateRow) error {
+// FlushGen mocks base method.
+func (m *MockPendingReaderPort) FlushGen() uint64 {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpsertAggregates", ctx, rows)
-	ret0, _ := ret[0].(error)
+	ret := m.ctrl.Call(m, "FlushGen")
+	ret0, _ := ret[0].(uint64)
 	return ret0
 }
 
-// UpsertAggregates indicates an expected call of UpsertAggregates.
-func (mr *MockAggregateWriterMockRecorder) UpsertAggregates(ctx, rows interface{}) *gomock.Call {
+// FlushGen indicates an expected call of FlushGen.
+func (mr *MockPendingReaderPortMockRecorder) FlushGen() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertAggregates", reflect.TypeOf((*MockAggregateWriter)(nil).UpsertAggregates), ctx, rows)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlushGen", reflect.TypeOf((*MockPendingReaderPort)(nil).FlushGen))
+}
+
+// Pending mocks base method.
+func (m *MockPendingReaderPort) Pending(ids []int64, from, to time.Time) ([]AggregateRow, uint64) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pending", ids, from, to)
+	ret0, _ := ret[0].([]AggregateRow)
+	ret1, _ := ret[1].(uint64)
+	return ret0, ret1
+}
+
+// Pending indicates an expected call of Pending.
+func (mr *MockPendingReaderPortMockRecorder) Pending(ids, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pending", reflect.TypeOf((*MockPendingReaderPort)(nil).Pending), ids, from, to)
+}
+
+// PendingSketches mocks base method.
+func (m *MockPendingReaderPort) PendingSketches(ids []int64, from, to time.Time) []SketchRow {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingSketches", ids, from, to)
+	ret0, _ := ret[0].([]SketchRow)
+	return ret0
+}
+
+// PendingSketches indicates an expected call of PendingSketches.
+func (mr *MockPendingReaderPortMockRecorder) PendingSketches(ids, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingSketches", reflect.TypeOf((*MockPendingReaderPort)(nil).PendingSketches), ids, from, to)
+}
+
+// PendingTotals mocks base method.
+func (m *MockPendingReaderPort) PendingTotals(from, to time.Time) (map[int64]int64, uint64) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingTotals", from, to)
+	ret0, _ := ret[0].(map[int64]int64)
+	ret1, _ := ret[1].(uint64)
+	return ret0, ret1
+}
+
+// PendingTotals indicates an expected call of PendingTotals.
+func (mr *MockPendingReaderPortMockRecorder) PendingTotals(from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingTotals", reflect.TypeOf((*MockPendingReaderPort)(nil).PendingTotals), from, to)
+}
+
+// MockBannerRegistryPort is a mock of BannerRegistryPort interface.
+type MockBannerRegistryPort struct {
+	ctrl     *gomock.Controller
+	recorder *MockBannerRegistryPortMockRecorder
+}
+
+// MockBannerRegistryPortMockRecorder is the mock recorder for MockBannerRegistryPort.
+type MockBannerRegistryPortMockRecorder struct {
+	mock *MockBannerRegistryPort
+}
+
+// NewMockBannerRegistryPort creates a new mock instance.
+func NewMockBannerRegistryPort(ctrl *gomock.Controller) *MockBannerRegistryPort {
+	mock := &MockBannerRegistryPort{ctrl: ctrl}
+	mock.recorder = &MockBannerRegistryPortMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBannerRegistryPort) EXPECT() *MockBannerRegistryPortMockRecorder {
+	return m.recorder
+}
+
+// Archive mocks base method.
+func (m *MockBannerRegistryPort) Archive(ctx context.Context, id int64) (Banner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Archive", ctx, id)
+	ret0, _ := ret[0].(Banner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Archive indicates an expected call of Archive.
+func (mr *MockBannerRegistryPortMockRecorder) Archive(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Archive", reflect.TypeOf((*MockBannerRegistryPort)(nil).Archive), ctx, id)
+}
+
+// Create mocks base method.
+func (m *MockBannerRegistryPort) Create(ctx context.Context, b Banner) (Banner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, b)
+	ret0, _ := ret[0].(Banner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockBannerRegistryPortMockRecorder) Create(ctx, b interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockBannerRegistryPort)(nil).Create), ctx, b)
+}
+
+// Get mocks base method.
+func (m *MockBannerRegistryPort) Get(id int64) (Banner, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", id)
+	ret0, _ := ret[0].(Banner)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockBannerRegistryPortMockRecorder) Get(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockBannerRegistryPort)(nil).Get), id)
+}
+
+// List mocks base method.
+func (m *MockBannerRegistryPort) List(ctx context.Context, withArchived bool) ([]Banner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, withArchived)
+	ret0, _ := ret[0].([]Banner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockBannerRegistryPortMockRecorder) List(ctx, withArchived interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockBannerRegistryPort)(nil).List), ctx, withArchived)
+}
+
+// Resolve mocks base method.
+func (m *MockBannerRegistryPort) Resolve(id int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resolve", id)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Resolve indicates an expected call of Resolve.
+func (mr *MockBannerRegistryPortMockRecorder) Resolve(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resolve", reflect.TypeOf((*MockBannerRegistryPort)(nil).Resolve), id)
+}
+
+// Update mocks base method.
+func (m *MockBannerRegistryPort) Update(ctx context.Context, b Banner) (Banner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, b)
+	ret0, _ := ret[0].(Banner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockBannerRegistryPortMockRecorder) Update(ctx, b interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockBannerRegistryPort)(nil).Update), ctx, b)
+}
+
+// MockBannerStorePort is a mock of BannerStorePort interface.
+type MockBannerStorePort struct {
+	ctrl     *gomock.Controller
+	recorder *MockBannerStorePortMockRecorder
+}
+
+// MockBannerStorePortMockRecorder is the mock recorder for MockBannerStorePort.
+type MockBannerStorePortMockRecorder struct {
+	mock *MockBannerStorePort
+}
+
+// NewMockBannerStorePort creates a new mock instance.
+func NewMockBannerStorePort(ctrl *gomock.Controller) *MockBannerStorePort {
+	mock := &MockBannerStorePort{ctrl: ctrl}
+	mock.recorder = &MockBannerStorePortMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBannerStorePort) EXPECT() *MockBannerStorePortMockRecorder {
+	return m.recorder
+}
+
+// ArchiveBanner mocks base method.
+func (m *MockBannerStorePort) ArchiveBanner(ctx context.Context, id int64) (Banner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchiveBanner", ctx, id)
+	ret0, _ := ret[0].(Banner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ArchiveBanner indicates an expected call of ArchiveBanner.
+func (mr *MockBannerStorePortMockRecorder) ArchiveBanner(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveBanner", reflect.TypeOf((*MockBannerStorePort)(nil).ArchiveBanner), ctx, id)
+}
+
+// CreateBanner mocks base method.
+func (m *MockBannerStorePort) CreateBanner(ctx context.Context, b Banner) (Banner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBanner", ctx, b)
+	ret0, _ := ret[0].(Banner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBanner indicates an expected call of CreateBanner.
+func (mr *MockBannerStorePortMockRecorder) CreateBanner(ctx, b interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBanner", reflect.TypeOf((*MockBannerStorePort)(nil).CreateBanner), ctx, b)
+}
+
+// ListBanners mocks base method.
+func (m *MockBannerStorePort) ListBanners(ctx context.Context) ([]Banner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBanners", ctx)
+	ret0, _ := ret[0].([]Banner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBanners indicates an expected call of ListBanners.
+func (mr *MockBannerStorePortMockRecorder) ListBanners(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBanners", reflect.TypeOf((*MockBannerStorePort)(nil).ListBanners), ctx)
+}
+
+// UpdateBanner mocks base method.
+func (m *MockBannerStorePort) UpdateBanner(ctx context.Context, b Banner) (Banner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBanner", ctx, b)
+	ret0, _ := ret[0].(Banner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateBanner indicates an expected call of UpdateBanner.
+func (mr *MockBannerStorePortMockRecorder) UpdateBanner(ctx, b interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBanner", reflect.TypeOf((*MockBannerStorePort)(nil).UpdateBanner), ctx, b)
+}
+
+// MockCampaignPort is a mock of CampaignPort interface.
+type MockCampaignPort struct {
+	ctrl     *gomock.Controller
+	recorder *MockCampaignPortMockRecorder
+}
+
+// MockCampaignPortMockRecorder is the mock recorder for MockCampaignPort.
+type MockCampaignPortMockRecorder struct {
+	mock *MockCampaignPort
+}
+
+// NewMockCampaignPort creates a new mock instance.
+func NewMockCampaignPort(ctrl *gomock.Controller) *MockCampaignPort {
+	mock := &MockCampaignPort{ctrl: ctrl}
+	mock.recorder = &MockCampaignPortMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCampaignPort) EXPECT() *MockCampaignPortMockRecorder {
+	return m.recorder
+}
+
+// AddBanner mocks base method.
+func (m *MockCampaignPort) AddBanner(ctx context.Context, campaignID, bannerID int64, from time.Time) (Membership, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddBanner", ctx, campaignID, bannerID, from)
+	ret0, _ := ret[0].(Membership)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddBanner indicates an expected call of AddBanner.
+func (mr *MockCampaignPortMockRecorder) AddBanner(ctx, campaignID, bannerID, from interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBanner", reflect.TypeOf((*MockCampaignPort)(nil).AddBanner), ctx, campaignID, bannerID, from)
+}
+
+// Create mocks base method.
+func (m *MockCampaignPort) Create(ctx context.Context, c Campaign) (Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, c)
+	ret0, _ := ret[0].(Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockCampaignPortMockRecorder) Create(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockCampaignPort)(nil).Create), ctx, c)
+}
+
+// Get mocks base method.
+func (m *MockCampaignPort) Get(ctx context.Context, id int64) (Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockCampaignPortMockRecorder) Get(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockCampaignPort)(nil).Get), ctx, id)
+}
+
+// List mocks base method.
+func (m *MockCampaignPort) List(ctx context.Context) ([]Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockCampaignPortMockRecorder) List(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockCampaignPort)(nil).List), ctx)
+}
+
+// Query mocks base method.
+func (m *MockCampaignPort) Query(ctx context.Context, campaignID int64, q StatsQuery) (*entity.StatsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", ctx, campaignID, q)
+	ret0, _ := ret[0].(*entity.StatsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Query indicates an expected call of Query.
+func (mr *MockCampaignPortMockRecorder) Query(ctx, campaignID, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockCampaignPort)(nil).Query), ctx, campaignID, q)
+}
+
+// RemoveBanner mocks base method.
+func (m *MockCampaignPort) RemoveBanner(ctx context.Context, campaignID, bannerID int64, at time.Time) (Membership, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveBanner", ctx, campaignID, bannerID, at)
+	ret0, _ := ret[0].(Membership)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveBanner indicates an expected call of RemoveBanner.
+func (mr *MockCampaignPortMockRecorder) RemoveBanner(ctx, campaignID, bannerID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveBanner", reflect.TypeOf((*MockCampaignPort)(nil).RemoveBanner), ctx, campaignID, bannerID, at)
+}
+
+// MockCampaignStorePort is a mock of CampaignStorePort interface.
+type MockCampaignStorePort struct {
+	ctrl     *gomock.Controller
+	recorder *MockCampaignStorePortMockRecorder
+}
+
+// MockCampaignStorePortMockRecorder is the mock recorder for MockCampaignStorePort.
+type MockCampaignStorePortMockRecorder struct {
+	mock *MockCampaignStorePort
+}
+
+// NewMockCampaignStorePort creates a new mock instance.
+func NewMockCampaignStorePort(ctrl *gomock.Controller) *MockCampaignStorePort {
+	mock := &MockCampaignStorePort{ctrl: ctrl}
+	mock.recorder = &MockCampaignStorePortMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCampaignStorePort) EXPECT() *MockCampaignStorePortMockRecorder {
+	return m.recorder
+}
+
+// AddCampaignBanner mocks base method.
+func (m_2 *MockCampaignStorePort) AddCampaignBanner(ctx context.Context, m Membership) (Membership, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "AddCampaignBanner", ctx, m)
+	ret0, _ := ret[0].(Membership)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddCampaignBanner indicates an expected call of AddCampaignBanner.
+func (mr *MockCampaignStorePortMockRecorder) AddCampaignBanner(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCampaignBanner", reflect.TypeOf((*MockCampaignStorePort)(nil).AddCampaignBanner), ctx, m)
+}
+
+// CampaignMembers mocks base method.
+func (m *MockCampaignStorePort) CampaignMembers(ctx context.Context, campaignID int64, from, to time.Time) ([]Membership, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CampaignMembers", ctx, campaignID, from, to)
+	ret0, _ := ret[0].([]Membership)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CampaignMembers indicates an expected call of CampaignMembers.
+func (mr *MockCampaignStorePortMockRecorder) CampaignMembers(ctx, campaignID, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CampaignMembers", reflect.TypeOf((*MockCampaignStorePort)(nil).CampaignMembers), ctx, campaignID, from, to)
+}
+
+// CreateCampaign mocks base method.
+func (m *MockCampaignStorePort) CreateCampaign(ctx context.Context, c Campaign) (Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCampaign", ctx, c)
+	ret0, _ := ret[0].(Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCampaign indicates an expected call of CreateCampaign.
+func (mr *MockCampaignStorePortMockRecorder) CreateCampaign(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCampaign", reflect.TypeOf((*MockCampaignStorePort)(nil).CreateCampaign), ctx, c)
+}
+
+// EndCampaignBanner mocks base method.
+func (m *MockCampaignStorePort) EndCampaignBanner(ctx context.Context, campaignID, bannerID int64, at time.Time) (Membership, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EndCampaignBanner", ctx, campaignID, bannerID, at)
+	ret0, _ := ret[0].(Membership)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EndCampaignBanner indicates an expected call of EndCampaignBanner.
+func (mr *MockCampaignStorePortMockRecorder) EndCampaignBanner(ctx, campaignID, bannerID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EndCampaignBanner", reflect.TypeOf((*MockCampaignStorePort)(nil).EndCampaignBanner), ctx, campaignID, bannerID, at)
+}
+
+// GetCampaign mocks base method.
+func (m *MockCampaignStorePort) GetCampaign(ctx context.Context, id int64) (Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCampaign", ctx, id)
+	ret0, _ := ret[0].(Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCampaign indicates an expected call of GetCampaign.
+func (mr *MockCampaignStorePortMockRecorder) GetCampaign(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCampaign", reflect.TypeOf((*MockCampaignStorePort)(nil).GetCampaign), ctx, id)
+}
+
+// ListCampaigns mocks base method.
+func (m *MockCampaignStorePort) ListCampaigns(ctx context.Context) ([]Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCampaigns", ctx)
+	ret0, _ := ret[0].([]Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCampaigns indicates an expected call of ListCampaigns.
+func (mr *MockCampaignStorePortMockRecorder) ListCampaigns(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCampaigns", reflect.TypeOf((*MockCampaignStorePort)(nil).ListCampaigns), ctx)
+}
+
+// MockHealthPort is a mock of HealthPort interface.
+type MockHealthPort struct {
+	ctrl     *gomock.Controller
+	recorder *MockHealthPortMockRecorder
+}
+
+// MockHealthPortMockRecorder is the mock recorder for MockHealthPort.
+type MockHealthPortMockRecorder struct {
+	mock *MockHealthPort
+}
+
+// NewMockHealthPort creates a new mock instance.
+func NewMockHealthPort(ctrl *gomock.Controller) *MockHealthPort {
+	mock := &MockHealthPort{ctrl: ctrl}
+	mock.recorder = &MockHealthPortMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHealthPort) EXPECT() *MockHealthPortMockRecorder {
+	return m.recorder
+}
+
+// Ready mocks base method.
+func (m *MockHealthPort) Ready(ctx context.Context) (entity.Readiness, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ready", ctx)
+	ret0, _ := ret[0].(entity.Readiness)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Ready indicates an expected call of Ready.
+func (mr *MockHealthPortMockRecorder) Ready(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ready", reflect.TypeOf((*MockHealthPort)(nil).Ready), ctx)
+}
+
+// MockPingerPort is a mock of PingerPort interface.
+type MockPingerPort struct {
+	ctrl     *gomock.Controller
+	recorder *MockPingerPortMockRecorder
+}
+
+// MockPingerPortMockRecorder is the mock recorder for MockPingerPort.
+type MockPingerPortMockRecorder struct {
+	mock *MockPingerPort
+}
+
+// NewMockPingerPort creates a new mock instance.
+func NewMockPingerPort(ctrl *gomock.Controller) *MockPingerPort {
+	mock := &MockPingerPort{ctrl: ctrl}
+	mock.recorder = &MockPingerPortMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPingerPort) EXPECT() *MockPingerPortMockRecorder {
+	return m.recorder
+}
+
+// Ping mocks base method.
+func (m *MockPingerPort) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockPingerPortMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockPingerPort)(nil).Ping), ctx)
+}
+
+// MockFlushStatusPort is a mock of FlushStatusPort interface.
+type MockFlushStatusPort struct {
+	ctrl     *gomock.Controller
+	recorder *MockFlushStatusPortMockRecorder
+}
+
+// MockFlushStatusPortMockRecorder is the mock recorder for MockFlushStatusPort.
+type MockFlushStatusPortMockRecorder struct {
+	mock *MockFlushStatusPort
+}
+
+// NewMockFlushStatusPort creates a new mock instance.
+func NewMockFlushStatusPort(ctrl *gomock.Controller) *MockFlushStatusPort {
+	mock := &MockFlushStatusPort{ctrl: ctrl}
+	mock.recorder = &MockFlushStatusPortMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFlushStatusPort) EXPECT() *MockFlushStatusPortMockRecorder {
+	return m.recorder
+}
+
+// BreakerState mocks base method.
+func (m *MockFlushStatusPort) BreakerState() (BreakerState, int) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BreakerState")
+	ret0, _ := ret[0].(BreakerState)
+	ret1, _ := ret[1].(int)
+	return ret0, ret1
+}
+
+// BreakerState indicates an expected call of BreakerState.
+func (mr *MockFlushStatusPortMockRecorder) BreakerState() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BreakerState", reflect.TypeOf((*MockFlushStatusPort)(nil).BreakerState))
+}
+
+// LastFlush mocks base method.
+func (m *MockFlushStatusPort) LastFlush() time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastFlush")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// LastFlush indicates an expected call of LastFlush.
+func (mr *MockFlushStatusPortMockRecorder) LastFlush() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastFlush", reflect.TypeOf((*MockFlushStatusPort)(nil).LastFlush))
+}
+
+// PendingKeys mocks base method.
+func (m *MockFlushStatusPort) PendingKeys() []int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingKeys")
+	ret0, _ := ret[0].([]int)
+	return ret0
+}
+
+// PendingKeys indicates an expected call of PendingKeys.
+func (mr *MockFlushStatusPortMockRecorder) PendingKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingKeys", reflect.TypeOf((*MockFlushStatusPort)(nil).PendingKeys))
+}
+
+// MockAggregateWriter is a mock of AggregateWriter interface.
+type MockAggregateWriter struct {
+	ctrl     *gomock.Controller
+	recorder *MockAggregateWriterMockRecorder
+}
+
+// MockAggregateWriterMockRecorder is the mock recorder for MockAggregateWriter.
+type MockAggregateWriterMockRecorder struct {
+	mock *MockAggregateWriter
+}
+
+// NewMockAggregateWriter creates a new mock instance.
+func NewMockAggregateWriter(ctrl *gomock.Controller) *MockAggregateWriter {
+	mock := &MockAggregateWriter{ctrl: ctrl}
+	mock.recorder = &MockAggregateWriterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAggregateWriter) EXPECT() *MockAggregateWriterMockRecorder {
+	return m.recorder
+}
+
+// MergeSketches mocks base method.
+func (m *MockAggregateWriter) MergeSketches(ctx context.Context, rows []SketchRow) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MergeSketches", ctx, rows)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MergeSketches indicates an expected call of MergeSketches.
+func (mr *MockAggregateWriterMockRecorder) MergeSketches(ctx, rows interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergeSketches", reflect.TypeOf((*MockAggregateWriter)(nil).MergeSketches), ctx, rows)
+}
+
+// UpsertAggregates mocks base method.
+func (m *MockAggregateWriter) UpsertAggregates(ctx context.Context, rows []AggregateRow) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertAggregates", ctx, rows)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertAggregates indicates an expected call of UpsertAggregates.
+func (mr *MockAggregateWriterMockRecorder) UpsertAggregates(ctx, rows interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertAggregates", reflect.TypeOf((*MockAggregateWriter)(nil).UpsertAggregates), ctx, rows)
+}
+
+// MockFlushObserver is a mock of FlushObserver interface.
+type MockFlushObserver struct {
+	ctrl     *gomock.Controller
+	recorder *MockFlushObserverMockRecorder
+}
+
+// MockFlushObserverMockRecorder is the mock recorder for MockFlushObserver.
+type MockFlushObserverMockRecorder struct {
+	mock *MockFlushObserver
+}
+
+// NewMockFlushObserver creates a new mock instance.
+func NewMockFlushObserver(ctrl *gomock.Controller) *MockFlushObserver {
+	mock := &MockFlushObserver{ctrl: ctrl}
+	mock.recorder = &MockFlushObserverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFlushObserver) EXPECT() *MockFlushObserverMockRecorder {
+	return m.recorder
+}
+
+// ObserveFlush mocks base method.
+func (m *MockFlushObserver) ObserveFlush(rows int, took time.Duration, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ObserveFlush", rows, took, err)
+}
+
+// ObserveFlush indicates an expected call of ObserveFlush.
+func (mr *MockFlushObserverMockRecorder) ObserveFlush(rows, took, err interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ObserveFlush", reflect.TypeOf((*MockFlushObserver)(nil).ObserveFlush), rows, took, err)
+}
+
+// MockFlushTracer is a mock of FlushTracer interface.
+type MockFlushTracer struct {
+	ctrl     *gomock.Controller
+	recorder *MockFlushTracerMockRecorder
+}
+
+// MockFlushTracerMockRecorder is the mock recorder for MockFlushTracer.
+type MockFlushTracerMockRecorder struct {
+	mock *MockFlushTracer
+}
+
+// NewMockFlushTracer creates a new mock instance.
+func NewMockFlushTracer(ctrl *gomock.Controller) *MockFlushTracer {
+	mock := &MockFlushTracer{ctrl: ctrl}
+	mock.recorder = &MockFlushTracerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFlushTracer) EXPECT() *MockFlushTracerMockRecorder {
+	return m.recorder
+}
+
+// StartFlush mocks base method.
+func (m *MockFlushTracer) StartFlush(ctx context.Context) (context.Context, func(int, error)) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartFlush", ctx)
+	ret0, _ := ret[0].(context.Context)
+	ret1, _ := ret[1].(func(int, error))
+	return ret0, ret1
+}
+
+// StartFlush indicates an expected call of StartFlush.
+func (mr *MockFlushTracerMockRecorder) StartFlush(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartFlush", reflect.TypeOf((*MockFlushTracer)(nil).StartFlush), ctx)
+}
+
+// MockJournal is a mock of Journal interface.
+type MockJournal struct {
+	ctrl     *gomock.Controller
+	recorder *MockJournalMockRecorder
+}
+
+// MockJournalMockRecorder is the mock recorder for MockJournal.
+type MockJournalMockRecorder struct {
+	mock *MockJournal
+}
+
+// NewMockJournal creates a new mock instance.
+func NewMockJournal(ctrl *gomock.Controller) *MockJournal {
+	mock := &MockJournal{ctrl: ctrl}
+	mock.recorder = &MockJournalMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockJournal) EXPECT() *MockJournalMockRecorder {
+	return m.recorder
+}
+
+// Append mocks base method.
+func (m *MockJournal) Append(ev Event) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Append", ev)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Append indicates an expected call of Append.
+func (mr *MockJournalMockRecorder) Append(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Append", reflect.TypeOf((*MockJournal)(nil).Append), ev)
+}
+
+// Commit mocks base method.
+func (m *MockJournal) Commit(checkpoint uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit", checkpoint)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *MockJournalMockRecorder) Commit(checkpoint interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockJournal)(nil).Commit), checkpoint)
+}
+
+// Replay mocks base method.
+func (m *MockJournal) Replay(fn func(Event)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replay", fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replay indicates an expected call of Replay.
+func (mr *MockJournalMockRecorder) Replay(fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replay", reflect.TypeOf((*MockJournal)(nil).Replay), fn)
+}
+
+// Rotate mocks base method.
+func (m *MockJournal) Rotate() (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockJournalMockRecorder) Rotate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockJournal)(nil).Rotate))
+}
+
+// Sync mocks base method.
+func (m *MockJournal) Sync() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sync")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Sync indicates an expected call of Sync.
+func (mr *MockJournalMockRecorder) Sync() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sync", reflect.TypeOf((*MockJournal)(nil).Sync))
+}
+
+// MockSpillLog is a mock of SpillLog interface.
+type MockSpillLog struct {
+	ctrl     *gomock.Controller
+	recorder *MockSpillLogMockRecorder
+}
+
+// MockSpillLogMockRecorder is the mock recorder for MockSpillLog.
+type MockSpillLogMockRecorder struct {
+	mock *MockSpillLog
+}
+
+// NewMockSpillLog creates a new mock instance.
+func NewMockSpillLog(ctrl *gomock.Controller) *MockSpillLog {
+	mock := &MockSpillLog{ctrl: ctrl}
+	mock.recorder = &MockSpillLogMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSpillLog) EXPECT() *MockSpillLogMockRecorder {
+	return m.recorder
+}
+
+// Append mocks base method.
+func (m *MockSpillLog) Append(ev Event) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Append", ev)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Append indicates an expected call of Append.
+func (mr *MockSpillLogMockRecorder) Append(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Append", reflect.TypeOf((*MockSpillLog)(nil).Append), ev)
+}
+
+// Commit mocks base method.
+func (m *MockSpillLog) Commit(checkpoint uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit", checkpoint)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *MockSpillLogMockRecorder) Commit(checkpoint interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockSpillLog)(nil).Commit), checkpoint)
+}
+
+// ReplayOldest mocks base method.
+func (m *MockSpillLog) ReplayOldest(limit int, fn func(Event)) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayOldest", limit, fn)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayOldest indicates an expected call of ReplayOldest.
+func (mr *MockSpillLogMockRecorder) ReplayOldest(limit, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayOldest", reflect.TypeOf((*MockSpillLog)(nil).ReplayOldest), limit, fn)
+}
+
+// Rotate mocks base method.
+func (m *MockSpillLog) Rotate() (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockSpillLogMockRecorder) Rotate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockSpillLog)(nil).Rotate))
 }
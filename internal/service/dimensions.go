@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// OtherValue заменяет значения сверх лимита кардинальности и некорректные значения.
+	OtherValue = "__other__"
+	// maxDimValueLen — максимальная длина значения измерения.
+	maxDimValueLen = 64
+)
+
+var ErrUnknownDimension = errors.New("unknown dimension")
+
+// Dims — канонически закодированные значения измерений клика
+// ("country=KZ&device=mobile", ключи по алфавиту, пустые значения опущены).
+// Пустая строка — клик без измерений.
+type Dims string
+
+func MakeDims(m map[string]string) Dims {
+	v := make(url.Values, len(m))
+	for k, val := range m {
+		if val != "" {
+			v.Set(k, val)
+		}
+	}
+	return Dims(v.Encode())
+}
+
+func (d Dims) Map() map[string]string {
+	out := map[string]string{}
+	if d == "" {
+		return out
+	}
+	v, _ := url.ParseQuery(string(d))
+	for k := range v {
+		out[k] = v.Get(k)
+	}
+	return out
+}
+
+// Project оставляет только измерения names.
+func (d Dims) Project(names []string) Dims {
+	if d == "" || len(names) == 0 {
+		return ""
+	}
+	m := d.Map()
+	out := make(map[string]string, len(names))
+	for _, n := range names {
+		out[n] = m[n]
+	}
+	return MakeDims(out)
+}
+
+// Contains сообщает, совпадают ли все значения filter со значениями d.
+func (d Dims) Contains(filter Dims) bool {
+	if filter == "" {
+		return true
+	}
+	m := d.Map()
+	for k, v := range filter.Map() {
+		if m[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Dimensions — набор настроенных измерений с лимитами кардинальности.
+// Значения запоминаются с момента старта; новые значения сверх лимита
+// учитываются как OtherValue, чтобы клиент не мог раздуть ключи шардов.
+type Dimensions struct {
+	limits   map[string]int
+	seen     map[string]*dimValues
+	overflow atomic.Int64
+}
+
+// dimValues — принятые значения одного измерения. Известные значения проверяются
+// под RLock; запись нужна только при приеме нового значения.
+type dimValues struct {
+	mu     sync.RWMutex
+	limit  int
+	values map[string]struct{}
+}
+
+func NewDimensions(limits map[string]int) *Dimensions {
+	seen := make(map[string]*dimValues, len(limits))
+	for name, limit := range limits {
+		seen[name] = &dimValues{limit: limit, values: make(map[string]struct{})}
+	}
+	return &Dimensions{limits: limits, seen: seen}
+}
+
+// Names — имена измерений по алфавиту.
+func (d *Dimensions) Names() []string {
+	if d == nil {
+		return nil
+	}
+	names := make([]string, 0, len(d.limits))
+	for n := range d.limits {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate проверяет, что все names — настроенные измерения.
+func (d *Dimensions) Validate(names ...string) error {
+	for _, n := range names {
+		if d == nil {
+			return ErrUnknownDimension
+		}
+		if _, ok := d.limits[n]; !ok {
+			return ErrUnknownDimension
+		}
+	}
+	return nil
+}
+
+// Capture собирает значения измерений клика через get (query-параметр, заголовок, поле батча).
+func (d *Dimensions) Capture(get func(name string) string) Dims {
+	if d == nil || len(d.limits) == 0 {
+		return ""
+	}
+	m := make(map[string]string, len(d.limits))
+	for name, dv := range d.seen {
+		v := strings.TrimSpace(get(name))
+		if v == "" {
+			continue
+		}
+		if dv.admit(v) {
+			m[name] = v
+		} else {
+			d.overflow.Add(1)
+			m[name] = OtherValue
+		}
+	}
+	return MakeDims(m)
+}
+
+// admit сообщает, учитывать ли v как есть (false — заменить на OtherValue).
+func (dv *dimValues) admit(v string) bool {
+	if len(v) > maxDimValueLen {
+		return false
+	}
+	dv.mu.RLock()
+	_, ok := dv.values[v]
+	full := len(dv.values) >= dv.limit
+	dv.mu.RUnlock()
+	if ok || full {
+		return ok
+	}
+	dv.mu.Lock()
+	defer dv.mu.Unlock()
+	if _, ok := dv.values[v]; ok {
+		return true
+	}
+	if len(dv.values) >= dv.limit {
+		return false
+	}
+	dv.values[v] = struct{}{}
+	return true
+}
+
+// Overflow — число значений, замененных на OtherValue с момента старта.
+func (d *Dimensions) Overflow() int64 {
+	if d == nil {
+		return 0
+	}
+	return d.overflow.Load()
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDimensions_CaptureCardinalityLimit(t *testing.T) {
+	d := NewDimensions(map[string]int{"country": 2, "device": 5})
+	capture := func(vals map[string]string) Dims {
+		return d.Capture(func(name string) string { return vals[name] })
+	}
+
+	if got := capture(map[string]string{"country": "KZ", "device": "mobile", "browser": "x"}); got != "country=KZ&device=mobile" {
+		t.Fatalf("unexpected dims %q", got)
+	}
+	capture(map[string]string{"country": "DE"})
+	// третье значение country сверх лимита, известное значение проходит
+	if got := capture(map[string]string{"country": "US"}); got != "country="+OtherValue {
+		t.Fatalf("expected overflow bucket, got %q", got)
+	}
+	if got := capture(map[string]string{"country": "KZ"}); got != "country=KZ" {
+		t.Fatalf("expected known value to pass, got %q", got)
+	}
+	if got := capture(map[string]string{"device": strings.Repeat("x", 100)}); got != "device="+OtherValue {
+		t.Fatalf("expected long value to be replaced, got %q", got)
+	}
+	if d.Overflow() != 2 {
+		t.Fatalf("expected 2 overflows, got %d", d.Overflow())
+	}
+}
+
+func TestDimensions_CaptureConcurrentLimit(t *testing.T) {
+	d := NewDimensions(map[string]int{"country": 10})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				d.Capture(func(string) string { return "c" + strconv.Itoa(i) })
+			}
+		}()
+	}
+	wg.Wait()
+	if got := len(d.seen["country"].values); got != 10 {
+		t.Fatalf("expected 10 admitted values, got %d", got)
+	}
+	if got := d.Overflow(); got != 8*90 {
+		t.Fatalf("expected %d overflowed values, got %d", 8*90, got)
+	}
+}
+
+func TestDims_ProjectAndContains(t *testing.T) {
+	d := MakeDims(map[string]string{"country": "KZ", "device": "mobile"})
+	if got := d.Project([]string{"device"}); got != "device=mobile" {
+		t.Fatalf("unexpected projection %q", got)
+	}
+	if !d.Contains(MakeDims(map[string]string{"country": "KZ"})) {
+		t.Fatalf("expected filter to match")
+	}
+	if d.Contains(MakeDims(map[string]string{"country": "DE"})) {
+		t.Fatalf("expected filter not to match")
+	}
+}
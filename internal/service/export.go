@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// ExportQuery — выгрузка строк всех баннеров за [From, To) с шагом Granularity
+// (только minute, hour или day — шаги таблиц хранилища). Границы выравниваются по UTC.
+type ExportQuery struct {
+	From, To    time.Time
+	Granularity Granularity
+}
+
+// Export implements StatsPort: передает в fn строки хранилища по мере чтения,
+// не собирая результат в память. Несохраненные клики агрегатора не учитываются.
+func (s *Stats) Export(ctx context.Context, q ExportQuery, fn func(AggregateRow) error) error {
+	switch q.Granularity {
+	case GranularityMinute, GranularityHour, GranularityDay:
+	default:
+		return ErrUnknownGranularity
+	}
+	if d := s.limits[q.Granularity]; d > 0 && q.To.Sub(q.From) > time.Hour*24*time.Duration(d) {
+		return ErrRangeTooLarge
+	}
+	from, to := q.Granularity.Truncate(q.From), q.Granularity.Truncate(q.To)
+	if !from.Before(to) {
+		return nil
+	}
+	return s.reader.ExportRange(ctx, q.Granularity.Resolution(), from, to, fn)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestStats_Export(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	stats := NewStats(reader, nil, nil, 7, nil, 0)
+
+	from := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(48 * time.Hour)
+	reader.EXPECT().ExportRange(gomock.Any(), ResolutionHour, from, to, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ Resolution, _, _ time.Time, fn func(AggregateRow) error) error {
+			return fn(AggregateRow{BannerID: 1, TS: from, Cnt: 2})
+		})
+
+	var got []AggregateRow
+	err := stats.Export(context.Background(), ExportQuery{From: from.Add(10 * time.Minute), To: to.Add(10 * time.Minute), Granularity: GranularityHour},
+		func(r AggregateRow) error {
+			got = append(got, r)
+			return nil
+		})
+	if err != nil || len(got) != 1 || got[0].Cnt != 2 {
+		t.Fatalf("unexpected result %v, %v", got, err)
+	}
+
+	if err := stats.Export(context.Background(), ExportQuery{From: from, To: to, Granularity: GranularityWeek}, nil); !errors.Is(err, ErrUnknownGranularity) {
+		t.Fatalf("expected ErrUnknownGranularity, got %v", err)
+	}
+	if err := stats.Export(context.Background(), ExportQuery{From: from, To: from.AddDate(0, 0, 8), Granularity: GranularityMinute}, nil); !errors.Is(err, ErrRangeTooLarge) {
+		t.Fatalf("expected ErrRangeTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+// Granularity — шаг точек в ответе /stats.
+type Granularity string
+
+const (
+	GranularityMinute    Granularity = "minute"
+	Granularity5Minutes  Granularity = "5m"
+	Granularity15Minutes Granularity = "15m"
+	GranularityHour      Granularity = "hour"
+	GranularityDay       Granularity = "day"
+	GranularityWeek      Granularity = "week"
+	GranularityMonth     Granularity = "month"
+)
+
+var ErrUnknownGranularity = errors.New("unknown granularity")
+
+// DefaultMaxDays — ограничения диапазона запроса по умолчанию для каждой гранулярности.
+var DefaultMaxDays = map[Granularity]int{
+	GranularityMinute:    7,
+	Granularity5Minutes:  31,
+	Granularity15Minutes: 92,
+	GranularityHour:      366,
+	GranularityDay:       732,
+	GranularityWeek:      1830,
+	GranularityMonth:     3660,
+}
+
+func ParseGranularity(s string) (Granularity, error) {
+	g := Granularity(s)
+	if _, ok := DefaultMaxDays[g]; !ok {
+		return "", ErrUnknownGranularity
+	}
+	return g, nil
+}
+
+// Resolution — самая грубая таблица хранилища, из которой собираются бакеты UTC.
+func (g Granularity) Resolution() Resolution {
+	switch g {
+	case GranularityHour:
+		return ResolutionHour
+	case GranularityDay, GranularityWeek, GranularityMonth:
+		return ResolutionDay
+	default:
+		return ResolutionMinute
+	}
+}
+
+// Truncate возвращает начало бакета UTC, содержащего t (недели начинаются с понедельника).
+func (g Granularity) Truncate(t time.Time) time.Time { return g.In(nil).Truncate(t) }
+
+// Next возвращает начало бакета UTC, следующего за бакетом, начинающимся в t.
+func (g Granularity) Next(t time.Time) time.Time { return g.In(nil).Next(t) }
+
+// In — бакеты гранулярности g по календарю часового пояса loc (nil — UTC).
+func (g Granularity) In(loc *time.Location) Buckets {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return Buckets{G: g, Loc: loc}
+}
+
+// Buckets — разбиение времени на бакеты гранулярности G. Часы, дни, недели и месяцы
+// начинаются на границах местного времени Loc: дни при переходе на летнее время
+// длятся 23 или 25 часов, часы в поясах со смещением на полчаса начинаются в :30 UTC.
+type Buckets struct {
+	G   Granularity
+	Loc *time.Location
+}
+
+// Truncate возвращает начало бакета, содержащего t, в часовом поясе b.Loc.
+func (b Buckets) Truncate(t time.Time) time.Time {
+	t = t.In(b.Loc)
+	switch b.G {
+	case Granularity5Minutes:
+		return t.Truncate(5 * time.Minute)
+	case Granularity15Minutes:
+		return t.Truncate(15 * time.Minute)
+	case GranularityHour:
+		// Через смещение, а не time.Date: повторяющийся при переводе часов
+		// местный час остается двумя разными бакетами
+		_, off := t.Zone()
+		shift := time.Duration(off) * time.Second
+		return t.Add(shift).Truncate(time.Hour).Add(-shift)
+	case GranularityDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, b.Loc)
+	case GranularityWeek:
+		return time.Date(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, 0, 0, 0, 0, b.Loc)
+	case GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, b.Loc)
+	default:
+		return t.Truncate(time.Minute)
+	}
+}
+
+// Ceil возвращает t, если это граница бакета, иначе конец бакета, содержащего t:
+// правая граница диапазона включает неполный бакет (например, текущий день).
+func (b Buckets) Ceil(t time.Time) time.Time {
+	start := b.Truncate(t)
+	if start.Equal(t) {
+		return start
+	}
+	return b.Next(start)
+}
+
+// Next возвращает начало бакета, следующего за бакетом, начинающимся в t.
+func (b Buckets) Next(t time.Time) time.Time {
+	t = t.In(b.Loc)
+	switch b.G {
+	case Granularity5Minutes:
+		return t.Add(5 * time.Minute)
+	case Granularity15Minutes:
+		return t.Add(15 * time.Minute)
+	case GranularityHour:
+		return b.Truncate(t.Add(time.Hour))
+	case GranularityDay:
+		return b.Truncate(t.AddDate(0, 0, 1))
+	case GranularityWeek:
+		return b.Truncate(t.AddDate(0, 0, 7))
+	case GranularityMonth:
+		return b.Truncate(t.AddDate(0, 1, 0))
+	default:
+		return t.Add(time.Minute)
+	}
+}
+
+// Resolution — самая грубая таблица хранилища, на шаг которой ложатся все границы
+// бакетов [from, to): роллапы хранятся по UTC, поэтому для местных дней подходят
+// только часовые (или, при смещениях не на целый час, минутные) строки.
+func (b Buckets) Resolution(from, to time.Time) Resolution {
+	res := b.G.Resolution()
+	for res > ResolutionMinute && !b.alignedTo(res.Step(), from, to) {
+		res--
+	}
+	return res
+}
+
+func (b Buckets) alignedTo(step time.Duration, from, to time.Time) bool {
+	for ts := from; ; ts = b.Next(ts) {
+		if !ts.Before(to) {
+			ts = to
+		}
+		if !ts.Equal(ts.Truncate(step)) {
+			return false
+		}
+		if ts.Equal(to) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func mustLoc(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("tz database: %v", err)
+	}
+	return loc
+}
+
+func TestBuckets_LocalDaysAcrossDST(t *testing.T) {
+	berlin := mustLoc(t, "Europe/Berlin")
+	days := GranularityDay.In(berlin)
+
+	tests := []struct {
+		name string
+		day  time.Time
+		want time.Duration
+	}{
+		{"spring forward", time.Date(2025, 3, 30, 0, 0, 0, 0, berlin), 23 * time.Hour},
+		{"fall back", time.Date(2025, 10, 26, 0, 0, 0, 0, berlin), 25 * time.Hour},
+		{"regular", time.Date(2025, 10, 27, 0, 0, 0, 0, berlin), 24 * time.Hour},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			start := days.Truncate(tc.day.Add(12 * time.Hour))
+			if !start.Equal(tc.day) {
+				t.Fatalf("expected day start %v, got %v", tc.day, start)
+			}
+			if got := days.Next(start).Sub(start); got != tc.want {
+				t.Fatalf("expected %v day, got %v", tc.want, got)
+			}
+		})
+	}
+
+	// Повторяющийся час 02:00-03:00 при переходе на зимнее время — два разных бакета
+	hours := GranularityHour.In(berlin)
+	first := time.Date(2025, 10, 26, 0, 0, 0, 0, time.UTC) // 02:00 CEST
+	if second := hours.Next(first); second.Sub(first) != time.Hour || second.Hour() != 2 {
+		t.Fatalf("expected second 02:00 bucket an hour later, got %v", second)
+	}
+}
+
+func TestBuckets_HalfHourOffset(t *testing.T) {
+	kolkata := mustLoc(t, "Asia/Kolkata")
+	hours := GranularityHour.In(kolkata)
+
+	ts := time.Date(2025, 10, 19, 10, 10, 0, 0, time.UTC) // 15:40 IST
+	want := time.Date(2025, 10, 19, 9, 30, 0, 0, time.UTC)
+	if got := hours.Truncate(ts); !got.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	// Границы местных часов не совпадают с часовыми роллапами UTC
+	if res := hours.Resolution(want, want.Add(5*time.Hour)); res != ResolutionMinute {
+		t.Fatalf("expected minute resolution, got %v", res)
+	}
+	almaty := mustLoc(t, "Asia/Almaty")
+	day := time.Date(2025, 10, 19, 0, 0, 0, 0, almaty)
+	if res := GranularityDay.In(almaty).Resolution(day, day.AddDate(0, 0, 7)); res != ResolutionHour {
+		t.Fatalf("expected hour resolution for local days, got %v", res)
+	}
+	if res := GranularityDay.In(nil).Resolution(day.UTC().Truncate(24*time.Hour), day.UTC().Truncate(24*time.Hour).AddDate(0, 0, 7)); res != ResolutionDay {
+		t.Fatalf("expected day resolution for UTC days, got %v", res)
+	}
+}
+
+func TestStats_Query_LocalDays(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	almaty := mustLoc(t, "Asia/Almaty") // UTC+5
+	reader := NewMockStatsReaderPort(ctrl)
+	stats := NewStats(reader, nil, nil, 90, nil, 0)
+
+	from := time.Date(2025, 10, 19, 0, 0, 0, 0, almaty)
+	to := from.AddDate(0, 0, 2)
+	reader.EXPECT().
+		QueryRange(gomock.Any(), RangeQuery{BannerID: 1, From: from, To: to, Resolution: ResolutionHour}).
+		Return([]AggregateRow{
+			{BannerID: 1, TS: time.Date(2025, 10, 19, 18, 0, 0, 0, time.UTC), Cnt: 2}, // 23:00 19.10 по Алматы
+			{BannerID: 1, TS: time.Date(2025, 10, 19, 19, 0, 0, 0, time.UTC), Cnt: 3}, // 00:00 20.10
+		}, nil)
+
+	resp, err := stats.Query(context.Background(), StatsQuery{BannerID: 1, From: from, To: to, Granularity: GranularityDay, Loc: almaty})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Stats) != 2 || !resp.Stats[0].TS.Equal(from) || resp.Stats[0].V != 2 || resp.Stats[1].V != 3 {
+		t.Fatalf("unexpected stats %v", resp.Stats)
+	}
+	if _, off := resp.Stats[1].TS.Zone(); off != 5*3600 {
+		t.Fatalf("expected local timestamps, got %v", resp.Stats[1].TS)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+)
+
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// Health — проверки готовности: доступность БД, давность последнего успешного flush,
+// число несохраненных ключей агрегатора и предохранитель flush.
+type Health struct {
+	db          PingerPort
+	flush       FlushStatusPort
+	dbTimeout   time.Duration
+	maxFlushAge time.Duration
+	maxPending  int
+	now         func() time.Time
+}
+
+// NewHealth: maxFlushAge и maxPending — пороги (0 — без проверки),
+// dbTimeout — таймаут проверки соединения с БД.
+func NewHealth(db PingerPort, flush FlushStatusPort, dbTimeout, maxFlushAge time.Duration, maxPending int) *Health {
+	return &Health{db: db, flush: flush, dbTimeout: dbTimeout, maxFlushAge: maxFlushAge, maxPending: maxPending, now: time.Now}
+}
+
+// Ready implements HealthPort: все проверки выполняются всегда, чтобы ответ описывал каждую.
+func (h *Health) Ready(ctx context.Context) (entity.Readiness, bool) {
+	checks := []entity.HealthCheck{h.checkDB(ctx), h.checkFlushAge(), h.checkPending(), h.checkBreaker()}
+	resp := entity.Readiness{Status: HealthOK, Checks: checks}
+	for _, c := range checks {
+		if c.Status != HealthOK {
+			resp.Status = HealthFail
+		}
+	}
+	return resp, resp.Status == HealthOK
+}
+
+func (h *Health) checkDB(ctx context.Context) entity.HealthCheck {
+	c := entity.HealthCheck{Name: "database", Status: HealthOK}
+	if h.dbTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.dbTimeout)
+		defer cancel()
+	}
+	if err := h.db.Ping(ctx); err != nil {
+		c.Status, c.Error = HealthFail, err.Error()
+	}
+	return c
+}
+
+func (h *Health) checkFlushAge() entity.HealthCheck {
+	age := h.now().Sub(h.flush.LastFlush()).Truncate(time.Millisecond)
+	c := entity.HealthCheck{Name: "flush", Status: HealthOK, Observed: age.String()}
+	if h.maxFlushAge > 0 {
+		c.Threshold = h.maxFlushAge.String()
+		if age > h.maxFlushAge {
+			c.Status = HealthFail
+		}
+	}
+	return c
+}
+
+func (h *Health) checkPending() entity.HealthCheck {
+	var n int
+	for _, k := range h.flush.PendingKeys() {
+		n += k
+	}
+	c := entity.HealthCheck{Name: "pending", Status: HealthOK, Observed: strconv.Itoa(n)}
+	if h.maxPending > 0 {
+		c.Threshold = strconv.Itoa(h.maxPending)
+		if n > h.maxPending {
+			c.Status = HealthFail
+		}
+	}
+	return c
+}
+
+// checkBreaker не проходит, пока предохранитель открыт: flush приостановлен после серии ошибок.
+func (h *Health) checkBreaker() entity.HealthCheck {
+	state, failures := h.flush.BreakerState()
+	c := entity.HealthCheck{Name: "breaker", Status: HealthOK, Observed: string(state)}
+	if state == BreakerOpen {
+		c.Status, c.Error = HealthFail, strconv.Itoa(failures)+" consecutive flush failures"
+	}
+	return c
+}
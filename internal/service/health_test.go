@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestHealth_Ready(t *testing.T) {
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		pingErr error
+		flushed time.Time
+		pending []int
+		breaker BreakerState
+		ok      bool
+		failed  string
+	}{
+		{"all ok", nil, now.Add(-2 * time.Second), []int{10, 20}, BreakerClosed, true, ""},
+		{"database down", errors.New("connection refused"), now, nil, BreakerClosed, false, "database"},
+		{"flush stuck", nil, now.Add(-5 * time.Minute), []int{1}, BreakerHalfOpen, false, "flush"},
+		{"backlog too large", nil, now, []int{60, 50}, BreakerClosed, false, "pending"},
+		{"breaker open", nil, now, []int{1}, BreakerOpen, false, "breaker"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			db := NewMockPingerPort(ctrl)
+			db.EXPECT().Ping(gomock.Any()).Return(tc.pingErr)
+			flush := NewMockFlushStatusPort(ctrl)
+			flush.EXPECT().LastFlush().Return(tc.flushed)
+			flush.EXPECT().PendingKeys().Return(tc.pending)
+			flush.EXPECT().BreakerState().Return(tc.breaker, 5)
+
+			h := NewHealth(db, flush, time.Second, time.Minute, 100)
+			h.now = func() time.Time { return now }
+			resp, ok := h.Ready(context.Background())
+			if ok != tc.ok || len(resp.Checks) != 4 {
+				t.Fatalf("expected ok=%v with 4 checks, got %v %+v", tc.ok, ok, resp)
+			}
+			for _, c := range resp.Checks {
+				if (c.Status == HealthFail) != (c.Name == tc.failed) {
+					t.Fatalf("unexpected check %+v", c)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LatePolicy — что делать с событием, время которого вне допустимого окна.
+type LatePolicy string
+
+const (
+	LateReject LatePolicy = "reject" // отклонить
+	LateClamp  LatePolicy = "clamp"  // прижать к границе окна
+	LateDivert LatePolicy = "count"  // не учитывать в баннере, только в счетчике опоздавших
+)
+
+var ErrUnknownLatePolicy = errors.New("unknown late policy")
+
+func ParseLatePolicy(s string) (LatePolicy, error) {
+	switch p := LatePolicy(s); p {
+	case LateReject, LateClamp, LateDivert:
+		return p, nil
+	default:
+		return "", ErrUnknownLatePolicy
+	}
+}
+
+// Admission — решение EventWindow по событию.
+type Admission int
+
+const (
+	Admitted Admission = iota // учитывать с возвращенным временем
+	Diverted                  // принять, но не учитывать
+	Rejected                  // отклонить
+)
+
+// EventWindow проверяет клиентское время события: не старше now-lateness
+// и не позже now+futureSkew; clamp прижимает время к ближайшей из этих границ.
+// Nil-окно пропускает любое время.
+type EventWindow struct {
+	lateness   time.Duration
+	futureSkew time.Duration
+	policy     LatePolicy
+
+	late   atomic.Int64
+	future atomic.Int64
+}
+
+func NewEventWindow(lateness, futureSkew time.Duration, policy LatePolicy) *EventWindow {
+	return &EventWindow{lateness: lateness, futureSkew: futureSkew, policy: policy}
+}
+
+func (w *EventWindow) Admit(ts, now time.Time) (time.Time, Admission) {
+	if w == nil {
+		return ts, Admitted
+	}
+	var edge time.Time
+	switch {
+	case ts.Before(now.Add(-w.lateness)):
+		w.late.Add(1)
+		edge = now.Add(-w.lateness)
+	case ts.After(now.Add(w.futureSkew)):
+		w.future.Add(1)
+		edge = now.Add(w.futureSkew)
+	default:
+		return ts, Admitted
+	}
+	switch w.policy {
+	case LateClamp:
+		return edge, Admitted
+	case LateDivert:
+		return ts, Diverted
+	default:
+		return ts, Rejected
+	}
+}
+
+// LateEvents — число событий старше окна с момента старта.
+func (w *EventWindow) LateEvents() int64 { return w.late.Load() }
+
+// FutureEvents — число событий из будущего сверх допустимого сдвига часов.
+func (w *EventWindow) FutureEvents() int64 { return w.future.Load() }
+
+// Report раз в every пишет в лог, сколько событий оказалось вне окна за период
+// (и всего с момента старта), если такие были. Работает до отмены ctx.
+func (w *EventWindow) Report(ctx context.Context, log *zap.Logger, every time.Duration) {
+	if w == nil {
+		return
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	var late, future int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			late, future = w.report(log, late, future)
+		}
+	}
+}
+
+// report логирует прирост счетчиков относительно late и future и возвращает текущие значения.
+func (w *EventWindow) report(log *zap.Logger, late, future int64) (int64, int64) {
+	nowLate, nowFuture := w.LateEvents(), w.FutureEvents()
+	if nowLate > late || nowFuture > future {
+		log.Warn("events outside time window",
+			zap.Int64("late", nowLate-late), zap.Int64("future", nowFuture-future),
+			zap.Int64("late_total", nowLate), zap.Int64("future_total", nowFuture),
+			zap.String("policy", string(w.policy)))
+	}
+	return nowLate, nowFuture
+}
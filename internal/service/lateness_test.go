@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestEventWindow_Admit(t *testing.T) {
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-2 * time.Hour)
+	future := now.Add(5 * time.Minute)
+
+	tests := []struct {
+		name    string
+		policy  LatePolicy
+		ts      time.Time
+		wantTS  time.Time
+		verdict Admission
+	}{
+		{"in window", LateReject, now.Add(-time.Minute), now.Add(-time.Minute), Admitted},
+		{"late rejected", LateReject, old, old, Rejected},
+		{"late clamped", LateClamp, old, now.Add(-time.Hour), Admitted},
+		{"late diverted", LateDivert, old, old, Diverted},
+		{"future clamped to skew edge", LateClamp, future, now.Add(time.Minute), Admitted},
+		{"future within skew", LateReject, now.Add(time.Minute), now.Add(time.Minute), Admitted},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewEventWindow(time.Hour, time.Minute, tc.policy)
+			ts, verdict := w.Admit(tc.ts, now)
+			if verdict != tc.verdict || !ts.Equal(tc.wantTS) {
+				t.Fatalf("expected (%s, %d), got (%s, %d)", tc.wantTS, tc.verdict, ts, verdict)
+			}
+		})
+	}
+
+	w := NewEventWindow(time.Hour, time.Minute, LateDivert)
+	w.Admit(old, now)
+	w.Admit(future, now)
+	if w.LateEvents() != 1 || w.FutureEvents() != 1 {
+		t.Fatalf("expected 1 late and 1 future event, got %d and %d", w.LateEvents(), w.FutureEvents())
+	}
+}
+
+func TestEventWindow_ReportLogsGrowth(t *testing.T) {
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+	w := NewEventWindow(time.Hour, time.Minute, LateDivert)
+	core, logs := observer.New(zap.WarnLevel)
+	log := zap.New(core)
+
+	w.Admit(now.Add(-2*time.Hour), now)
+	w.Admit(now.Add(-3*time.Hour), now)
+	late, future := w.report(log, 0, 0)
+	if late != 2 || future != 0 || logs.Len() != 1 {
+		t.Fatalf("expected one report of 2 late events, got %d %d, %d entries", late, future, logs.Len())
+	}
+	if f := logs.All()[0].ContextMap(); f["late"] != int64(2) || f["late_total"] != int64(2) {
+		t.Fatalf("unexpected fields %v", f)
+	}
+
+	// без новых опоздавших событий в лог ничего не пишется
+	if late, future = w.report(log, late, future); logs.Len() != 1 {
+		t.Fatalf("expected no report without growth, got %d entries", logs.Len())
+	}
+	w.Admit(now.Add(time.Hour), now)
+	w.report(log, late, future)
+	if f := logs.All()[1].ContextMap(); f["late"] != int64(0) || f["future"] != int64(1) || f["late_total"] != int64(2) {
+		t.Fatalf("unexpected fields %v", f)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import "container/heap"
+
+// minutes — счетчики шарда, сгруппированные по минутам. Куча минут отдает самую раннюю
+// минуту за O(log n), поэтому drop_oldest не просматривает весь шард на каждое событие.
+type minutes struct {
+	byMinute map[int64]map[key]counts
+	order    minuteHeap // минуты byMinute; удаленные выбрасываются при просмотре вершины
+	n        int        // число ключей
+}
+
+func newMinutes() *minutes { return &minutes{byMinute: make(map[int64]map[key]counts)} }
+
+func (m *minutes) len() int {
+	if m == nil {
+		return 0
+	}
+	return m.n
+}
+
+func (m *minutes) has(k key) bool {
+	_, ok := m.byMinute[k.minute][k]
+	return ok
+}
+
+// add добавляет c к ключу k и возвращает true, если ключ появился.
+func (m *minutes) add(k key, c counts) bool {
+	keys := m.byMinute[k.minute]
+	if keys == nil {
+		keys = make(map[key]counts)
+		m.byMinute[k.minute] = keys
+		heap.Push(&m.order, k.minute)
+	}
+	cur, ok := keys[k]
+	cur.clicks += c.clicks
+	cur.imps += c.imps
+	keys[k] = cur
+	if !ok {
+		m.n++
+	}
+	return !ok
+}
+
+// merge добавляет src в m и возвращает число ключей, которые уже были в m.
+func (m *minutes) merge(src *minutes) int {
+	var dup int
+	src.each(func(k key, c counts) {
+		if !m.add(k, c) {
+			dup++
+		}
+	})
+	return dup
+}
+
+func (m *minutes) remove(k key) bool {
+	keys := m.byMinute[k.minute]
+	if _, ok := keys[k]; !ok {
+		return false
+	}
+	delete(keys, k)
+	if len(keys) == 0 {
+		delete(m.byMinute, k.minute)
+	}
+	m.n--
+	return true
+}
+
+// sub вычитает c из ключа k, не опускаясь ниже нуля; обнулившийся ключ удаляется.
+// Возвращает true, если ключ удален.
+func (m *minutes) sub(k key, c counts) bool {
+	cur, ok := m.byMinute[k.minute][k]
+	if !ok {
+		return false
+	}
+	cur.clicks = max(cur.clicks-c.clicks, 0)
+	cur.imps = max(cur.imps-c.imps, 0)
+	if cur.clicks == 0 && cur.imps == 0 {
+		return m.remove(k)
+	}
+	m.byMinute[k.minute][k] = cur
+	return false
+}
+
+// oldest — самая ранняя минута; ok == false, если ключей нет.
+func (m *minutes) oldest() (minute int64, ok bool) {
+	if m == nil {
+		return 0, false
+	}
+	for m.order.Len() > 0 {
+		if _, ok := m.byMinute[m.order[0]]; ok {
+			return m.order[0], true
+		}
+		heap.Pop(&m.order)
+	}
+	return 0, false
+}
+
+// drop удаляет и возвращает ключи минуты.
+func (m *minutes) drop(minute int64) map[key]counts {
+	if m == nil {
+		return nil
+	}
+	keys := m.byMinute[minute]
+	delete(m.byMinute, minute)
+	m.n -= len(keys)
+	return keys
+}
+
+func (m *minutes) each(fn func(k key, c counts)) {
+	if m == nil {
+		return
+	}
+	for _, keys := range m.byMinute {
+		for k, c := range keys {
+			fn(k, c)
+		}
+	}
+}
+
+type minuteHeap []int64
+
+func (h minuteHeap) Len() int           { return len(h) }
+func (h minuteHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h minuteHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minuteHeap) Push(x any)        { *h = append(*h, x.(int64)) }
+func (h *minuteHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrOverloaded            = errors.New("aggregator overloaded")
+	ErrUnknownOverflowPolicy = errors.New("unknown overflow policy")
+	errSpill                 = errors.New("spill")
+	errDrop                  = errors.New("drop")
+)
+
+// OverflowPolicy — что делать с событием, для которого нужен новый ключ,
+// когда в агрегаторе уже MaxKeys несохраненных ключей.
+type OverflowPolicy string
+
+const (
+	OverflowReject     OverflowPolicy = "reject"      // отклонить (ErrOverloaded, 503)
+	OverflowSpill      OverflowPolicy = "spill"       // записать на диск, вернуть в шарды после flush
+	OverflowDropOldest OverflowPolicy = "drop_oldest" // вытеснить самую старую минуту шарда с учетом потерь
+)
+
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(s); p {
+	case OverflowReject, OverflowSpill, OverflowDropOldest:
+		return p, nil
+	default:
+		return "", ErrUnknownOverflowPolicy
+	}
+}
+
+// Limit ограничивает число несохраненных ключей агрегата (0 — без ограничения).
+// Для OverflowSpill нужен UseSpill. Вызывается до Run и до первого Add.
+func (a *Aggregator) Limit(maxKeys int, policy OverflowPolicy) {
+	a.maxKeys, a.policy = int64(maxKeys), policy
+}
+
+// UseSpill подключает журнал, в который OverflowSpill складывает события сверх лимита.
+// После успешного flush в шарды возвращается не больше batch записей (0 — без ограничения);
+// записи прошлых запусков возвращаются так же.
+func (a *Aggregator) UseSpill(j SpillLog, batch int) { a.spill, a.spillBatch = j, batch }
+
+// reserve проверяет место для события ev с ключом k; вызывается под локом шарда sh.
+// Место занимают новый ключ и новый скетч посетителей. Для drop_oldest лимит может быть
+// превышен не больше чем на два ключа на шард: вытесняются только ключи своего шарда.
+// Событие не новее самой ранней минуты шарда само оказывается самым старым: вместо
+// вытеснения более новых минут отбрасывается оно (errDrop).
+func (a *Aggregator) reserve(sh *shard, k key, ev Event) error {
+	if a.maxKeys <= 0 {
+		return nil
+	}
+	var need int64
+	if !sh.data.has(k) {
+		need++
+	}
+	if ev.Kind != KindImpression && ev.Visitor != 0 {
+		if _, ok := sh.sketches[skey{banner: k.banner, minute: k.minute}]; !ok {
+			need++
+		}
+	}
+	if need == 0 || a.keys.Load()+need <= a.maxKeys {
+		return nil
+	}
+	switch a.policy {
+	case OverflowDropOldest:
+		for a.keys.Load()+need > a.maxKeys {
+			oldest, ok := sh.oldest()
+			if !ok {
+				break
+			}
+			if k.minute <= oldest {
+				return errDrop
+			}
+			a.evictOldest(sh, oldest)
+		}
+		return nil
+	case OverflowSpill:
+		if a.spill != nil {
+			return errSpill
+		}
+	}
+	return ErrOverloaded
+}
+
+// oldest — самая ранняя минута среди ключей шарда, которые можно вытеснить: снапшот,
+// который сейчас записывает flush (flushing), не вытесняется, он попадет в БД.
+func (sh *shard) oldest() (int64, bool) {
+	oldest, ok := sh.data.oldest()
+	if m, found := sh.sealed.oldest(); found && (!ok || m < oldest) {
+		oldest, ok = m, true
+	}
+	return oldest, ok
+}
+
+// evictOldest удаляет из шарда ключи и скетчи минуты oldest и учитывает клики и показы
+// как потерянные. Вытеснение записывается в журнал отрицательными событиями, чтобы
+// replay не вернул потерянное.
+func (a *Aggregator) evictOldest(sh *shard, oldest int64) {
+	for _, keys := range [...]map[key]counts{sh.data.drop(oldest), sh.sealed.drop(oldest)} {
+		for k, c := range keys {
+			a.droppedClicks.Add(c.clicks)
+			a.droppedImps.Add(c.imps)
+			a.keys.Add(-1)
+			a.journalEviction(k.row(c))
+			sk := skey{banner: k.banner, minute: k.minute}
+			if _, found := sh.sketches[sk]; found {
+				delete(sh.sketches, sk)
+				a.keys.Add(-1)
+			}
+		}
+	}
+}
+
+// drop учитывает событие как потерянное.
+func (a *Aggregator) drop(ev Event) {
+	if ev.Kind == KindImpression {
+		a.droppedImps.Add(ev.Count)
+	} else {
+		a.droppedClicks.Add(ev.Count)
+	}
+}
+
+// journalEviction записывает вытесненную строку в журнал с обратным знаком.
+func (a *Aggregator) journalEviction(r AggregateRow) {
+	if a.journal == nil {
+		return
+	}
+	for _, ev := range r.events() {
+		ev.Count = -ev.Count
+		if _, err := a.journal.Append(ev); err != nil {
+			a.journalErrs.Add(1)
+		}
+	}
+}
+
+// spillEvent записывает событие в spill-журнал.
+func (a *Aggregator) spillEvent(ev Event) error {
+	ev.TS = minuteUTC(ev.TS)
+	if _, err := a.spill.Append(ev); err != nil {
+		a.log.Warn("spill append failed", zap.Error(err))
+		return ErrOverloaded
+	}
+	a.spilled.Add(ev.Count)
+	return nil
+}
+
+// drainSpill возвращает в шарды самые старые события spill-журнала, не больше spillBatch
+// записей за вызов. То, что снова не помещается, записывается в новый сегмент spill,
+// прочитанные сегменты удаляются. Вызывается из Run после успешного flush.
+func (a *Aggregator) drainSpill() {
+	if a.spill == nil || (a.maxKeys > 0 && a.keys.Load() >= a.maxKeys) {
+		return
+	}
+	// Ротация делает читаемыми записи активного сегмента
+	if _, err := a.spill.Rotate(); err != nil {
+		a.log.Warn("spill rotate failed", zap.Error(err))
+		return
+	}
+	var lost int
+	cp, err := a.spill.ReplayOldest(a.spillBatch, func(ev Event) {
+		if err := a.Add(ev); err != nil {
+			lost++
+			a.drop(ev)
+		}
+	})
+	if err != nil {
+		a.log.Warn("spill replay failed", zap.Error(err))
+		return
+	}
+	if lost > 0 {
+		a.log.Warn("spilled events lost", zap.Int("records", lost))
+	}
+	if cp == 0 {
+		return
+	}
+	// Возвращенные события должны быть на диске в журнале раньше, чем их удалит spill
+	if a.journal != nil {
+		if err := a.journal.Sync(); err != nil {
+			a.log.Warn("journal sync failed", zap.Error(err))
+			return
+		}
+	}
+	if err := a.spill.Commit(cp); err != nil {
+		a.log.Warn("spill commit failed", zap.Error(err))
+	}
+}
+
+// Dropped — клики и показы, вытесненные политикой drop_oldest (или потерянные при возврате из spill).
+func (a *Aggregator) Dropped() (clicks, impressions int64) {
+	return a.droppedClicks.Load(), a.droppedImps.Load()
+}
+
+// Spilled — сколько событий (с учетом Count) записано в spill-журнал с момента старта,
+// включая повторные записи при возврате.
+func (a *Aggregator) Spilled() int64 { return a.spilled.Load() }
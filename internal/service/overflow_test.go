@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestAggregator_Limit_Reject(t *testing.T) {
+	agg := NewAggregator(zap.NewNop(), nil, 4, time.Hour)
+	agg.Limit(2, OverflowReject)
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	if err := agg.Inc(1, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := agg.Inc(2, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := agg.Inc(3, now); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded for a new key, got %v", err)
+	}
+	// существующий ключ места не занимает
+	if err := agg.Inc(1, now.Add(time.Second)); err != nil {
+		t.Fatalf("unexpected error for existing key: %v", err)
+	}
+}
+
+func TestAggregator_Limit_DropOldest(t *testing.T) {
+	agg := NewAggregator(zap.NewNop(), nil, 1, time.Hour)
+	agg.Limit(2, OverflowDropOldest)
+	t0 := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	_ = agg.Add(Event{BannerID: 1, TS: t0, Count: 5})
+	_ = agg.Add(Event{BannerID: 2, TS: t0, Count: 2, Kind: KindImpression})
+	_ = agg.Add(Event{BannerID: 1, TS: t0.Add(time.Minute), Count: 1})
+	if err := agg.Add(Event{BannerID: 1, TS: t0.Add(2 * time.Minute), Count: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clicks, imps := agg.Dropped()
+	if clicks != 5 || imps != 2 {
+		t.Fatalf("expected 5 clicks and 2 impressions dropped, got %d and %d", clicks, imps)
+	}
+	if keys := agg.PendingKeys(); keys[0] != 2 {
+		t.Fatalf("expected 2 pending keys, got %v", keys)
+	}
+	if rows, _ := agg.Pending([]int64{1}, t0, t0.Add(time.Hour)); len(rows) != 2 {
+		t.Fatalf("expected the two newest minutes to stay, got %v", rows)
+	}
+}
+
+func TestAggregator_Limit_DropOldestDropsLateEvent(t *testing.T) {
+	agg := NewAggregator(zap.NewNop(), nil, 1, time.Hour)
+	agg.Limit(1, OverflowDropOldest)
+	t0 := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	_ = agg.Add(Event{BannerID: 1, TS: t0, Count: 5})
+
+	// и более раннее событие, и новый ключ той же минуты не вытесняют текущую минуту
+	for _, ev := range []Event{{BannerID: 2, TS: t0.Add(-time.Minute), Count: 2}, {BannerID: 3, TS: t0, Count: 1}} {
+		if err := agg.Add(ev); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if clicks, _ := agg.Dropped(); clicks != 3 {
+		t.Fatalf("expected the 3 incoming clicks dropped, got %d", clicks)
+	}
+	rows, _ := agg.Pending([]int64{1, 2, 3}, t0.Add(-time.Hour), t0.Add(time.Hour))
+	if len(rows) != 1 || rows[0].BannerID != 1 || rows[0].Cnt != 5 {
+		t.Fatalf("expected the current minute kept, got %v", rows)
+	}
+}
+
+func TestAggregator_Limit_DropOldestSkipsFlushing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockW := NewMockAggregateWriter(ctrl)
+	agg := NewAggregator(zap.NewNop(), mockW, 1, time.Hour)
+	agg.Limit(2, OverflowDropOldest)
+	t0 := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	_ = agg.Inc(1, t0)
+
+	// пока минута t0 записывается, приходят новые клики: вытесняется только то, что не в записи
+	mockW.EXPECT().
+		UpsertAggregates(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, rows []AggregateRow) error {
+			_ = agg.Add(Event{BannerID: 1, TS: t0, Count: 4})
+			_ = agg.Inc(1, t0.Add(time.Minute))
+			if len(rows) != 1 || rows[0].Cnt != 1 {
+				t.Fatalf("expected the snapshot row, got %#v", rows)
+			}
+			return nil
+		})
+	if err := agg.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if clicks, _ := agg.Dropped(); clicks != 4 {
+		t.Fatalf("expected 4 dropped clicks, got %d", clicks)
+	}
+	rows, _ := agg.Pending([]int64{1}, t0, t0.Add(time.Hour))
+	if len(rows) != 1 || !rows[0].TS.Equal(t0.Add(time.Minute)) {
+		t.Fatalf("expected only the newest minute pending, got %v", rows)
+	}
+}
+
+func TestAggregator_Limit_SketchesCountAsKeys(t *testing.T) {
+	agg := NewAggregator(zap.NewNop(), nil, 1, time.Hour)
+	agg.Limit(2, OverflowReject)
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+
+	if err := agg.Add(Event{BannerID: 1, TS: now, Count: 1, Visitor: VisitorHash("a")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// ключ и скетч уже занимают лимит
+	if err := agg.Inc(2, now); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded, got %v", err)
+	}
+	if err := agg.Add(Event{BannerID: 1, TS: now, Count: 1, Visitor: VisitorHash("b")}); err != nil {
+		t.Fatalf("unexpected error for existing key and sketch: %v", err)
+	}
+}
+
+func TestAggregator_Limit_SpillAndDrain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockW := NewMockAggregateWriter(ctrl)
+	spill := NewMockSpillLog(ctrl)
+	agg := NewAggregator(zap.NewNop(), mockW, 1, time.Hour)
+	agg.Limit(1, OverflowSpill)
+	agg.UseSpill(spill, 100)
+	now := time.Date(2025, 10, 19, 0, 29, 10, 0, time.UTC)
+	spilled := Event{BannerID: 2, TS: now.Truncate(time.Minute), Count: 3}
+
+	spill.EXPECT().Append(spilled).Return(uint64(1), nil)
+	_ = agg.Inc(1, now)
+	if err := agg.Add(Event{BannerID: 2, TS: now, Count: 3}); err != nil {
+		t.Fatalf("expected the event to be spilled, got %v", err)
+	}
+	if agg.Spilled() != 3 {
+		t.Fatalf("expected 3 spilled, got %d", agg.Spilled())
+	}
+
+	// после flush место освобождается, и события из spill возвращаются в шарды
+	gomock.InOrder(
+		mockW.EXPECT().UpsertAggregates(gomock.Any(), gomock.Any()).Return(nil),
+		spill.EXPECT().Rotate().Return(uint64(2), nil),
+		spill.EXPECT().ReplayOldest(100, gomock.Any()).DoAndReturn(func(_ int, fn func(Event)) (uint64, error) {
+			fn(spilled)
+			return 2, nil
+		}),
+		spill.EXPECT().Commit(uint64(2)).Return(nil),
+	)
+	if err := agg.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	agg.drainSpill()
+	rows, _ := agg.Pending([]int64{2}, now.Add(-time.Hour), now.Add(time.Hour))
+	if len(rows) != 1 || rows[0].Cnt != 3 {
+		t.Fatalf("expected spilled clicks back in the shard, got %v", rows)
+	}
+}
+
+func TestAggregator_Limit_SpillDrainSyncsJournal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	journal := NewMockJournal(ctrl)
+	spill := NewMockSpillLog(ctrl)
+	agg := NewAggregator(zap.NewNop(), nil, 1, time.Hour)
+	agg.Limit(10, OverflowSpill)
+	agg.UseSpill(spill, 10)
+	journal.EXPECT().Replay(gomock.Any()).Return(nil)
+	if err := agg.UseJournal(journal); err != nil {
+		t.Fatalf("use journal: %v", err)
+	}
+	now := time.Date(2025, 10, 19, 0, 29, 0, 0, time.UTC)
+	spilled := Event{BannerID: 2, TS: now, Count: 3}
+
+	// spill-сегменты удаляются только после fsync журнала, куда вернулись события
+	gomock.InOrder(
+		spill.EXPECT().Rotate().Return(uint64(2), nil),
+		spill.EXPECT().ReplayOldest(10, gomock.Any()).DoAndReturn(func(_ int, fn func(Event)) (uint64, error) {
+			fn(spilled)
+			return 2, nil
+		}),
+		journal.EXPECT().Sync().Return(nil),
+		spill.EXPECT().Commit(uint64(2)).Return(nil),
+	)
+	journal.EXPECT().Append(spilled).Return(uint64(1), nil)
+	agg.drainSpill()
+}
@@ -0,0 +1,435 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+)
+
+var (
+	ErrRangeTooLarge  = errors.New("range too large")
+	ErrUnknownFill    = errors.New("unknown fill")
+	ErrTooManyBanners = errors.New("too many banners")
+)
+
+// Fill — способ заполнения бакетов без данных.
+type Fill string
+
+const (
+	FillNone     Fill = "none"     // только бакеты с данными
+	FillZero     Fill = "zero"     // пустые бакеты со значением 0
+	FillPrevious Fill = "previous" // значение предыдущего бакета (0 до первого)
+	FillNull     Fill = "null"     // пустые бакеты со значением null
+)
+
+func ParseFill(s string) (Fill, error) {
+	switch f := Fill(s); f {
+	case "":
+		return FillNone, nil
+	case FillNone, FillZero, FillPrevious, FillNull:
+		return f, nil
+	default:
+		return "", ErrUnknownFill
+	}
+}
+
+// StatsQuery — запрос статистики за [From, To). Пустая Granularity означает
+// поминутные точки с общим лимитом диапазона (поведение до появления гранулярности).
+type StatsQuery struct {
+	BannerID    int64
+	From, To    time.Time
+	Granularity Granularity
+	Fill        Fill
+	// IncludePending добавляет к данным БД еще не записанные клики агрегатора.
+	IncludePending bool
+	Filter         Dims
+	GroupBy        []string
+	// Uniques добавляет оценку уникальных посетителей по бакетам и за весь диапазон
+	// (только для ряда баннера целиком: скетчи не разбиваются по измерениям).
+	Uniques bool
+	// Summary добавляет сводку по возвращаемому ряду и рядам групп.
+	Summary bool
+	// Compare добавляет сравнение с предыдущим диапазоном (только клики, без групп).
+	Compare Compare
+	// Loc — часовой пояс границ бакетов и меток времени в ответе (nil — UTC).
+	Loc *time.Location
+}
+
+// buckets — бакеты точек ответа (поминутные, если гранулярность не задана).
+func (q StatsQuery) buckets() Buckets {
+	if q.Granularity == "" {
+		return GranularityMinute.In(q.Loc)
+	}
+	return q.Granularity.In(q.Loc)
+}
+
+// Stats собирает строки из StatsReaderPort в бакеты запрошенной гранулярности.
+type Stats struct {
+	reader     StatsReaderPort
+	pending    PendingReaderPort
+	dims       *Dimensions
+	maxDays    int
+	limits     map[Granularity]int
+	maxBanners int
+}
+
+// overlayAttempts — сколько раз повторить чтение, если оно пересеклось с flush.
+const overlayAttempts = 5
+
+// NewStats: maxDays — лимит для запросов без гранулярности, limits — по гранулярностям
+// (отсутствующие берутся из DefaultMaxDays, 0 — без ограничения).
+// maxBanners — лимит баннеров в QueryMulti (0 — без ограничения).
+// pending может быть nil — тогда IncludePending игнорируется.
+func NewStats(reader StatsReaderPort, pending PendingReaderPort, dims *Dimensions, maxDays int, limits map[Granularity]int, maxBanners int) *Stats {
+	merged := make(map[Granularity]int, len(DefaultMaxDays))
+	for g, d := range DefaultMaxDays {
+		merged[g] = d
+	}
+	for g, d := range limits {
+		if _, ok := merged[g]; ok {
+			merged[g] = d
+		}
+	}
+	return &Stats{reader: reader, pending: pending, dims: dims, maxDays: maxDays, limits: merged, maxBanners: maxBanners}
+}
+
+// Span — интервал [From, To), за который учитываются данные баннера
+// (нулевая граница — без ограничения с этой стороны).
+type Span struct {
+	BannerID int64
+	From, To time.Time
+}
+
+// Query implements StatsPort. from выравнивается вниз до начала бакета, to — вверх до его конца.
+func (s *Stats) Query(ctx context.Context, q StatsQuery) (*entity.StatsResponse, error) {
+	return withComparison(q, func(q StatsQuery) (*entity.StatsResponse, error) {
+		return s.QuerySpans(ctx, q, []Span{{BannerID: q.BannerID}})
+	})
+}
+
+// QuerySpans суммирует ряды нескольких баннеров, каждый — только в пределах своего
+// интервала (q.BannerID и q.Compare не используются). Границы интервалов выравниваются до минуты.
+func (s *Stats) QuerySpans(ctx context.Context, q StatsQuery, spans []Span) (*entity.StatsResponse, error) {
+	g, err := s.validate(q)
+	if err != nil {
+		return nil, err
+	}
+
+	b := g.In(q.Loc)
+	from, to := b.Truncate(q.From), b.Ceil(q.To)
+	var rqs []RangeQuery
+	for _, sp := range spans {
+		lo, hi := from, to
+		if t := minuteUTC(sp.From); !sp.From.IsZero() && t.After(lo) {
+			lo = t
+		}
+		if t := minuteUTC(sp.To); !sp.To.IsZero() && t.Before(hi) {
+			hi = t
+		}
+		if !lo.Before(hi) {
+			continue
+		}
+		rqs = append(rqs, RangeQuery{
+			BannerID:   sp.BannerID,
+			From:       lo,
+			To:         hi,
+			Resolution: b.Resolution(lo, hi),
+			Filter:     q.Filter,
+			GroupBy:    q.GroupBy,
+		})
+	}
+	rows, err := s.read(ctx, q, rqs)
+	if err != nil {
+		return nil, err
+	}
+	var sketches []SketchRow
+	if q.Uniques {
+		if sketches, err = s.sketches(ctx, q, rqs); err != nil {
+			return nil, err
+		}
+	}
+	return response(q, b, from, to, rows, sketches), nil
+}
+
+// QueryMulti implements StatsPort: ряды нескольких баннеров одним запросом к хранилищу
+// (q.BannerID не используется, повторы в ids игнорируются).
+func (s *Stats) QueryMulti(ctx context.Context, q StatsQuery, ids []int64) (map[int64]*entity.StatsResponse, error) {
+	ids = uniqueIDs(ids)
+	if s.maxBanners > 0 && len(ids) > s.maxBanners {
+		return nil, ErrTooManyBanners
+	}
+	g, err := s.validate(q)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[int64]*entity.StatsResponse, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	b := g.In(q.Loc)
+	from, to := b.Truncate(q.From), b.Ceil(q.To)
+	rqs := []RangeQuery{{
+		BannerIDs:  ids,
+		From:       from,
+		To:         to,
+		Resolution: b.Resolution(from, to),
+		Filter:     q.Filter,
+		GroupBy:    q.GroupBy,
+	}}
+	rows, err := s.read(ctx, q, rqs)
+	if err != nil {
+		return nil, err
+	}
+	byBanner := make(map[int64][]AggregateRow, len(ids))
+	for _, r := range rows {
+		byBanner[r.BannerID] = append(byBanner[r.BannerID], r)
+	}
+	sketches := make(map[int64][]SketchRow)
+	if q.Uniques {
+		all, err := s.sketches(ctx, q, rqs)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range all {
+			sketches[r.BannerID] = append(sketches[r.BannerID], r)
+		}
+	}
+	for _, id := range ids {
+		out[id] = response(q, b, from, to, byBanner[id], sketches[id])
+	}
+	if q.Compare.Mode != "" {
+		pq := q.previous()
+		prev, err := s.QueryMulti(ctx, pq, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			out[id].Compare = comparison(q, pq, out[id].Stats, prev[id].Stats)
+		}
+	}
+	return out, nil
+}
+
+// validate проверяет лимит диапазона и измерения запроса и возвращает его гранулярность.
+func (s *Stats) validate(q StatsQuery) (Granularity, error) {
+	g, maxDays := q.Granularity, s.limits[q.Granularity]
+	if g == "" {
+		g, maxDays = GranularityMinute, s.maxDays
+	}
+	if maxDays > 0 && q.To.Sub(q.From) > time.Hour*24*time.Duration(maxDays) {
+		return "", ErrRangeTooLarge
+	}
+	if err := s.dims.Validate(q.GroupBy...); err != nil {
+		return "", err
+	}
+	for name := range q.Filter.Map() {
+		if err := s.dims.Validate(name); err != nil {
+			return "", err
+		}
+	}
+	return g, nil
+}
+
+// read читает строки запросов из БД, при q.IncludePending — вместе с шардами агрегатора.
+func (s *Stats) read(ctx context.Context, q StatsQuery, rqs []RangeQuery) ([]AggregateRow, error) {
+	if q.IncludePending && s.pending != nil {
+		return s.queryWithPending(ctx, rqs)
+	}
+	return s.queryRanges(ctx, rqs)
+}
+
+// sketches читает скетчи посетителей запросов, при q.IncludePending — вместе с несохраненными.
+func (s *Stats) sketches(ctx context.Context, q StatsQuery, rqs []RangeQuery) ([]SketchRow, error) {
+	var out []SketchRow
+	for _, rq := range rqs {
+		sk, err := s.reader.QueryUniques(ctx, rq)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sk...)
+		if q.IncludePending && s.pending != nil {
+			out = append(out, s.pending.PendingSketches(rq.Banners(), rq.From, rq.To)...)
+		}
+	}
+	return out, nil
+}
+
+// response собирает ответ из отсортированных по времени строк и скетчей (при q.Uniques).
+func response(q StatsQuery, b Buckets, from, to time.Time, rows []AggregateRow, sketches []SketchRow) *entity.StatsResponse {
+	resp := &entity.StatsResponse{Stats: fill(series(rows, b), b, q.Fill, from, to)}
+	if len(q.GroupBy) > 0 {
+		resp.Groups = groups(rows, b, q.Fill, from, to)
+	}
+	if q.Uniques {
+		resp.Uniques = withUniques(resp.Stats, sketches, b, q.Fill)
+	}
+	if q.Summary {
+		resp.Summary = summarize(resp.Stats)
+		for i := range resp.Groups {
+			resp.Groups[i].Summary = summarize(resp.Groups[i].Stats)
+		}
+	}
+	return resp
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// queryRanges читает строки всех запросов, упорядоченные по времени.
+func (s *Stats) queryRanges(ctx context.Context, qs []RangeQuery) ([]AggregateRow, error) {
+	var rows []AggregateRow
+	for _, q := range qs {
+		part, err := s.reader.QueryRange(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, part...)
+	}
+	if len(qs) > 1 {
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].TS.Before(rows[j].TS) })
+	}
+	return rows, nil
+}
+
+// withUniques проставляет точкам оценку уникальных посетителей их бакета и возвращает
+// оценку за весь диапазон. Достроенные fill бакеты получают 0 (или предыдущее значение
+// при fill=previous), null-бакеты остаются без оценки.
+func withUniques(pts []entity.Point, sketches []SketchRow, b Buckets, f Fill) *int64 {
+	buckets, total := mergeSketches(sketches, b)
+	var prev int64
+	for i := range pts {
+		if pts[i].Null {
+			continue
+		}
+		var v int64
+		if hll := buckets[pts[i].TS]; hll != nil {
+			v = int64(hll.Estimate())
+		} else if f == FillPrevious {
+			v = prev
+		}
+		prev = v
+		pts[i].Uniques = &v
+	}
+	n := int64(total.Estimate())
+	return &n
+}
+
+// queryWithPending читает БД и шарды агрегатора между двумя отсчетами FlushGen.
+// Если за время чтения flush начался или завершился, неизвестно, видела ли БД его
+// батч, и чтение повторяется; после overlayAttempts возвращаются только данные БД,
+// чтобы не посчитать клики дважды.
+func (s *Stats) queryWithPending(ctx context.Context, qs []RangeQuery) ([]AggregateRow, error) {
+attempts:
+	for attempt := 0; attempt < overlayAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+			}
+		}
+		gen := s.pending.FlushGen()
+		if gen%2 == 1 {
+			continue
+		}
+		rows, err := s.queryRanges(ctx, qs)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range qs {
+			mem, genAfter := s.pending.Pending(q.Banners(), q.From, q.To)
+			if gen != genAfter {
+				continue attempts
+			}
+			for _, r := range mem {
+				if r.Dims.Contains(q.Filter) {
+					r.Dims = r.Dims.Project(q.GroupBy)
+					rows = append(rows, r)
+				}
+			}
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].TS.Before(rows[j].TS) })
+		return rows, nil
+	}
+	return s.queryRanges(ctx, qs)
+}
+
+// series суммирует отсортированные по времени строки в бакеты b
+// и считает CTR бакетов с показами.
+func series(rows []AggregateRow, b Buckets) []entity.Point {
+	var out []entity.Point
+	for _, r := range rows {
+		ts := b.Truncate(r.TS)
+		if n := len(out); n > 0 && out[n-1].TS.Equal(ts) {
+			out[n-1].V += r.Cnt
+			out[n-1].Impressions += r.Imps
+			continue
+		}
+		out = append(out, entity.Point{TS: ts, V: r.Cnt, Impressions: r.Imps})
+	}
+	for i := range out {
+		if out[i].Impressions > 0 {
+			ctr := float64(out[i].V) / float64(out[i].Impressions)
+			out[i].CTR = &ctr
+		}
+	}
+	return out
+}
+
+// groups строит отдельный ряд для каждого набора значений измерений (по алфавиту).
+func groups(rows []AggregateRow, b Buckets, f Fill, from, to time.Time) []entity.GroupStats {
+	byDims := make(map[Dims][]AggregateRow)
+	for _, r := range rows {
+		byDims[r.Dims] = append(byDims[r.Dims], r)
+	}
+	keys := make([]Dims, 0, len(byDims))
+	for d := range byDims {
+		keys = append(keys, d)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	out := make([]entity.GroupStats, 0, len(keys))
+	for _, d := range keys {
+		out = append(out, entity.GroupStats{Dims: d.Map(), Stats: fill(series(byDims[d], b), b, f, from, to)})
+	}
+	return out
+}
+
+// fill достраивает плотный ряд бакетов на [from, to) по правилу f.
+func fill(pts []entity.Point, b Buckets, f Fill, from, to time.Time) []entity.Point {
+	if f == "" || f == FillNone {
+		return pts
+	}
+	var out []entity.Point
+	var prev entity.Point
+	i := 0
+	for ts := from; ts.Before(to); ts = b.Next(ts) {
+		if i < len(pts) && pts[i].TS.Equal(ts) {
+			prev = pts[i]
+			out = append(out, pts[i])
+			i++
+			continue
+		}
+		switch f {
+		case FillZero:
+			out = append(out, entity.Point{TS: ts})
+		case FillPrevious:
+			out = append(out, entity.Point{TS: ts, V: prev.V, Impressions: prev.Impressions, CTR: prev.CTR})
+		case FillNull:
+			out = append(out, entity.Point{TS: ts, Null: true})
+		}
+	}
+	return out
+}
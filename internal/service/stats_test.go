@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/hyperloglog"
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/golang/mock/gomock"
+)
+
+func TestStats_Query_BucketsByGranularity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	stats := NewStats(reader, nil, nil, 90, nil, 0)
+
+	// 2025-10-15 — среда; неделя начинается в понедельник 13-го, to — граница недели
+	from := time.Date(2025, 10, 15, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 10, 27, 0, 0, 0, 0, time.UTC)
+	monday := time.Date(2025, 10, 13, 0, 0, 0, 0, time.UTC)
+
+	reader.EXPECT().
+		QueryRange(gomock.Any(), RangeQuery{BannerID: 1, From: monday, To: monday.AddDate(0, 0, 14), Resolution: ResolutionDay}).
+		Return([]AggregateRow{
+			{BannerID: 1, TS: monday.AddDate(0, 0, 2), Cnt: 3},
+			{BannerID: 1, TS: monday.AddDate(0, 0, 6), Cnt: 4},
+			{BannerID: 1, TS: monday.AddDate(0, 0, 7), Cnt: 5},
+		}, nil)
+
+	resp, err := stats.Query(context.Background(), StatsQuery{BannerID: 1, From: from, To: to, Granularity: GranularityWeek})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := resp.Stats
+	want := []entity.Point{{TS: monday, V: 7}, {TS: monday.AddDate(0, 0, 7), V: 5}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestStats_Query_IncludesBucketOfTo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	stats := NewStats(reader, nil, nil, 90, nil, 0)
+
+	day := time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)
+	// to посреди текущего дня: день читается целиком и попадает в ответ
+	reader.EXPECT().
+		QueryRange(gomock.Any(), RangeQuery{BannerID: 1, From: day.AddDate(0, 0, -1), To: day.AddDate(0, 0, 1), Resolution: ResolutionDay}).
+		Return([]AggregateRow{
+			{BannerID: 1, TS: day.AddDate(0, 0, -1), Cnt: 2},
+			{BannerID: 1, TS: day, Cnt: 6},
+		}, nil)
+
+	resp, err := stats.Query(context.Background(), StatsQuery{
+		BannerID: 1, From: day.AddDate(0, 0, -1), To: day.Add(13*time.Hour + 30*time.Minute), Granularity: GranularityDay,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []entity.Point{{TS: day.AddDate(0, 0, -1), V: 2}, {TS: day, V: 6}}
+	if len(resp.Stats) != len(want) || resp.Stats[0] != want[0] || resp.Stats[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, resp.Stats)
+	}
+}
+
+func TestStats_Query_RangeLimitPerGranularity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	stats := NewStats(reader, nil, nil, 90, map[Granularity]int{GranularityHour: 2}, 0)
+
+	from := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		g    Granularity
+		days int
+		ok   bool
+	}{
+		{"legacy minute within READ_MAX_RANGE_DAYS", "", 30, true},
+		{"explicit minute over default", GranularityMinute, 8, false},
+		{"hour over override", GranularityHour, 3, false},
+		{"day within default", GranularityDay, 700, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.ok {
+				reader.EXPECT().QueryRange(gomock.Any(), gomock.Any()).Return(nil, nil)
+			}
+			_, err := stats.Query(context.Background(), StatsQuery{BannerID: 1, From: from, To: from.AddDate(0, 0, tc.days), Granularity: tc.g})
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.ok && !errors.Is(err, ErrRangeTooLarge) {
+				t.Fatalf("expected ErrRangeTooLarge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	from := time.Date(2025, 10, 19, 10, 0, 0, 0, time.UTC)
+	to := from.Add(4 * time.Hour)
+	pts := []entity.Point{{TS: from.Add(time.Hour), V: 3}}
+
+	tests := []struct {
+		fill Fill
+		want []int64
+		null []bool
+	}{
+		{FillNone, []int64{3}, []bool{false}},
+		{FillZero, []int64{0, 3, 0, 0}, []bool{false, false, false, false}},
+		{FillPrevious, []int64{0, 3, 3, 3}, []bool{false, false, false, false}},
+		{FillNull, []int64{0, 3, 0, 0}, []bool{true, false, true, true}},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.fill), func(t *testing.T) {
+			got := fill(pts, GranularityHour.In(nil), tc.fill, from, to)
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %d points, got %v", len(tc.want), got)
+			}
+			for i := range got {
+				if got[i].V != tc.want[i] || got[i].Null != tc.null[i] {
+					t.Fatalf("point %d: expected v=%d null=%v, got %+v", i, tc.want[i], tc.null[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStats_Query_IncludePendingRetriesAcrossFlush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	pending := NewMockPendingReaderPort(ctrl)
+	stats := NewStats(reader, pending, nil, 90, nil, 0)
+
+	from := time.Date(2025, 10, 19, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	rq := RangeQuery{BannerID: 1, From: from, To: to, Resolution: ResolutionHour}
+	gomock.InOrder(
+		// первое чтение пересеклось с flush (gen 2 -> 4): результат отбрасывается
+		pending.EXPECT().FlushGen().Return(uint64(2)),
+		reader.EXPECT().QueryRange(gomock.Any(), rq).
+			Return([]AggregateRow{{BannerID: 1, TS: from, Cnt: 1}}, nil),
+		pending.EXPECT().Pending([]int64{1}, from, to).
+			Return([]AggregateRow{{BannerID: 1, TS: from.Add(time.Minute), Cnt: 2}}, uint64(4)),
+		// второе чтение согласовано
+		pending.EXPECT().FlushGen().Return(uint64(4)),
+		reader.EXPECT().QueryRange(gomock.Any(), rq).
+			Return([]AggregateRow{{BannerID: 1, TS: from, Cnt: 3}}, nil),
+		pending.EXPECT().Pending([]int64{1}, from, to).
+			Return([]AggregateRow{{BannerID: 1, TS: from.Add(2 * time.Minute), Cnt: 1}}, uint64(4)),
+	)
+
+	resp, err := stats.Query(context.Background(), StatsQuery{
+		BannerID: 1, From: from, To: to, Granularity: GranularityHour, IncludePending: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := resp.Stats
+	if len(got) != 1 || got[0].V != 4 {
+		t.Fatalf("expected single bucket with 4 clicks, got %v", got)
+	}
+}
+
+func TestStats_Query_GroupByWithPending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	pending := NewMockPendingReaderPort(ctrl)
+	dims := NewDimensions(map[string]int{"country": 10, "device": 10})
+	stats := NewStats(reader, pending, dims, 90, nil, 0)
+
+	from := time.Date(2025, 10, 19, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	filter := MakeDims(map[string]string{"device": "mobile"})
+
+	pending.EXPECT().FlushGen().Return(uint64(0)).AnyTimes()
+	reader.EXPECT().
+		QueryRange(gomock.Any(), RangeQuery{
+			BannerID: 1, From: from, To: to, Resolution: ResolutionHour,
+			Filter: filter, GroupBy: []string{"country"},
+		}).
+		Return([]AggregateRow{
+			{BannerID: 1, TS: from, Dims: "country=DE", Cnt: 1},
+			{BannerID: 1, TS: from, Dims: "country=KZ", Cnt: 2},
+		}, nil)
+	pending.EXPECT().Pending([]int64{1}, from, to).Return([]AggregateRow{
+		{BannerID: 1, TS: from.Add(time.Minute), Dims: "country=KZ&device=mobile", Cnt: 3},
+		{BannerID: 1, TS: from.Add(time.Minute), Dims: "country=KZ&device=desktop", Cnt: 100},
+	}, uint64(0))
+
+	resp, err := stats.Query(context.Background(), StatsQuery{
+		BannerID: 1, From: from, To: to, Granularity: GranularityHour,
+		IncludePending: true, Filter: filter, GroupBy: []string{"country"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Stats) != 1 || resp.Stats[0].V != 6 {
+		t.Fatalf("expected total 6, got %v", resp.Stats)
+	}
+	if len(resp.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %+v", resp.Groups)
+	}
+	de, kz := resp.Groups[0], resp.Groups[1]
+	if de.Dims["country"] != "DE" || de.Stats[0].V != 1 || kz.Dims["country"] != "KZ" || kz.Stats[0].V != 5 {
+		t.Fatalf("unexpected groups: %+v", resp.Groups)
+	}
+
+	if _, err := stats.Query(context.Background(), StatsQuery{
+		BannerID: 1, From: from, To: to, GroupBy: []string{"browser"},
+	}); !errors.Is(err, ErrUnknownDimension) {
+		t.Fatalf("expected ErrUnknownDimension, got %v", err)
+	}
+}
+
+func sketchOf(t *testing.T, visitors ...string) []byte {
+	t.Helper()
+	var b []byte
+	for _, v := range visitors {
+		hll := hyperloglog.New()
+		hll.InsertHash(VisitorHash(v))
+		one, err := hll.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if b, err = MergeSketch(b, one); err != nil {
+			t.Fatalf("merge: %v", err)
+		}
+	}
+	return b
+}
+
+func TestStats_Query_Uniques(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	stats := NewStats(reader, nil, nil, 90, nil, 0)
+	from := time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)
+	to := from.Add(3 * time.Hour)
+
+	reader.EXPECT().QueryRange(gomock.Any(), gomock.Any()).Return([]AggregateRow{
+		{BannerID: 1, TS: from.Add(time.Minute), Cnt: 2},
+		{BannerID: 1, TS: from.Add(5 * time.Minute), Cnt: 2},
+		{BannerID: 1, TS: from.Add(2 * time.Hour), Cnt: 1},
+	}, nil)
+	reader.EXPECT().QueryUniques(gomock.Any(), gomock.Any()).Return([]SketchRow{
+		{BannerID: 1, TS: from.Add(time.Minute), Sketch: sketchOf(t, "a", "b")},
+		{BannerID: 1, TS: from.Add(5 * time.Minute), Sketch: sketchOf(t, "b", "c")},
+		{BannerID: 1, TS: from.Add(2 * time.Hour), Sketch: sketchOf(t, "c")},
+	}, nil)
+
+	resp, err := stats.Query(context.Background(), StatsQuery{
+		BannerID: 1, From: from, To: to, Granularity: GranularityHour, Fill: FillZero, Uniques: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []int64{3, 0, 1}
+	if len(resp.Stats) != len(want) {
+		t.Fatalf("expected %d points, got %d", len(want), len(resp.Stats))
+	}
+	for i, p := range resp.Stats {
+		if p.Uniques == nil || *p.Uniques != want[i] {
+			t.Fatalf("point %d: expected uniques %d, got %v", i, want[i], p.Uniques)
+		}
+	}
+	if resp.Uniques == nil || *resp.Uniques != 3 {
+		t.Fatalf("expected 3 uniques in total, got %v", resp.Uniques)
+	}
+}
+
+func TestSeries_ImpressionsAndCTR(t *testing.T) {
+	from := time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)
+	rows := []AggregateRow{
+		{BannerID: 1, TS: from.Add(10 * time.Minute), Cnt: 1, Imps: 40},
+		{BannerID: 1, TS: from.Add(20 * time.Minute), Cnt: 1, Imps: 60},
+		{BannerID: 1, TS: from.Add(2 * time.Hour), Cnt: 3},
+	}
+	got := fill(series(rows, GranularityHour.In(nil)), GranularityHour.In(nil), FillPrevious, from, from.Add(3*time.Hour))
+	if len(got) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(got))
+	}
+	if got[0].V != 2 || got[0].Impressions != 100 || got[0].CTR == nil || *got[0].CTR != 0.02 {
+		t.Fatalf("unexpected first bucket: %+v", got[0])
+	}
+	if got[1].Impressions != 100 || got[1].CTR != got[0].CTR {
+		t.Fatalf("expected previous bucket carried over, got %+v", got[1])
+	}
+	if got[2].V != 3 || got[2].Impressions != 0 || got[2].CTR != nil {
+		t.Fatalf("expected no CTR without impressions, got %+v", got[2])
+	}
+}
+
+func TestStats_QueryMulti_SingleReadSplitByBanner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	stats := NewStats(reader, nil, nil, 90, nil, 3)
+
+	from := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	reader.EXPECT().
+		QueryRange(gomock.Any(), RangeQuery{BannerIDs: []int64{1, 2, 3}, From: from, To: to, Resolution: ResolutionHour}).
+		Return([]AggregateRow{
+			{BannerID: 1, TS: from, Cnt: 2},
+			{BannerID: 2, TS: from, Cnt: 5},
+			{BannerID: 1, TS: from.Add(time.Hour), Cnt: 1},
+		}, nil)
+
+	q := StatsQuery{From: from, To: to, Granularity: GranularityHour, Fill: FillZero}
+	res, err := stats.QueryMulti(context.Background(), q, []int64{1, 2, 1, 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("expected 3 banners, got %v", res)
+	}
+	want := map[int64][]int64{1: {2, 1}, 2: {5, 0}, 3: {0, 0}}
+	for id, vs := range want {
+		pts := res[id].Stats
+		if len(pts) != len(vs) {
+			t.Fatalf("banner %d: expected %v, got %v", id, vs, pts)
+		}
+		for i, v := range vs {
+			if pts[i].V != v {
+				t.Fatalf("banner %d: expected %v, got %v", id, vs, pts)
+			}
+		}
+	}
+
+	if _, err := stats.QueryMulti(context.Background(), q, []int64{1, 2, 3, 4}); !errors.Is(err, ErrTooManyBanners) {
+		t.Fatalf("expected ErrTooManyBanners, got %v", err)
+	}
+}
+
+func TestStats_QueryMulti_PendingReadOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	pending := NewMockPendingReaderPort(ctrl)
+	stats := NewStats(reader, pending, nil, 90, nil, 0)
+
+	from := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	pending.EXPECT().FlushGen().Return(uint64(2))
+	reader.EXPECT().QueryRange(gomock.Any(), gomock.Any()).Return(nil, nil)
+	// один вызов на все баннеры, а не по вызову на баннер
+	pending.EXPECT().Pending([]int64{1, 2}, from, to).Return([]AggregateRow{
+		{BannerID: 1, TS: from, Cnt: 2},
+		{BannerID: 2, TS: from, Cnt: 5},
+	}, uint64(2))
+
+	q := StatsQuery{From: from, To: to, Granularity: GranularityHour, IncludePending: true}
+	res, err := stats.QueryMulti(context.Background(), q, []int64{1, 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res[1].Stats[0].V != 2 || res[2].Stats[0].V != 5 {
+		t.Fatalf("unexpected series: %+v %+v", res[1], res[2])
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	ts := time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)
+	pts := []entity.Point{
+		{TS: ts, V: 4, Impressions: 40},
+		{TS: ts.Add(time.Hour), V: 10},
+		{TS: ts.Add(2 * time.Hour), Null: true},
+		{TS: ts.Add(3 * time.Hour), V: 0},
+		{TS: ts.Add(4 * time.Hour), V: 10},
+		{TS: ts.Add(5 * time.Hour), V: 6},
+	}
+	got := summarize(pts)
+	if got.Total != 30 || got.Impressions != 40 || got.Buckets != 5 || got.Min != 0 || got.Max != 10 || got.Avg != 6 {
+		t.Fatalf("unexpected summary %+v", got)
+	}
+	if got.PeakTS == nil || !got.PeakTS.Equal(ts.Add(time.Hour)) {
+		t.Fatalf("expected first peak at 01:00, got %v", got.PeakTS)
+	}
+	// sorted: 0 4 6 10 10
+	want := map[string]float64{"p50": 6, "p90": 10, "p95": 10, "p99": 10}
+	for k, v := range want {
+		if got.Percentiles[k] != v {
+			t.Fatalf("%s: expected %v, got %v", k, v, got.Percentiles[k])
+		}
+	}
+	if p := percentile([]int64{0, 10}, 25); p != 2.5 {
+		t.Fatalf("expected interpolated 2.5, got %v", p)
+	}
+
+	if empty := summarize(nil); empty.Buckets != 0 || empty.PeakTS != nil || empty.Percentiles != nil {
+		t.Fatalf("unexpected empty summary %+v", empty)
+	}
+}
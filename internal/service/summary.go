@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+)
+
+// summaryPercentiles — перцентили кликов по бакетам в сводке.
+var summaryPercentiles = []float64{50, 90, 95, 99}
+
+// summarize считает сводку по кликам точек ряда (null-бакеты не учитываются).
+func summarize(pts []entity.Point) *entity.Summary {
+	sum := &entity.Summary{}
+	vals := make([]int64, 0, len(pts))
+	for _, p := range pts {
+		if p.Null {
+			continue
+		}
+		if len(vals) == 0 || p.V < sum.Min {
+			sum.Min = p.V
+		}
+		if len(vals) == 0 || p.V > sum.Max {
+			sum.Max = p.V
+			ts := p.TS
+			sum.PeakTS = &ts
+		}
+		sum.Total += p.V
+		sum.Impressions += p.Impressions
+		vals = append(vals, p.V)
+	}
+	sum.Buckets = len(vals)
+	if len(vals) == 0 {
+		return sum
+	}
+	sum.Avg = float64(sum.Total) / float64(len(vals))
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	sum.Percentiles = make(map[string]float64, len(summaryPercentiles))
+	for _, p := range summaryPercentiles {
+		sum.Percentiles[fmt.Sprintf("p%g", p)] = percentile(vals, p)
+	}
+	return sum
+}
+
+// percentile — p-й перцентиль отсортированных значений с линейной интерполяцией
+// между соседними рангами.
+func percentile(sorted []int64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	frac := rank - float64(lo)
+	return float64(sorted[lo]) + frac*float64(sorted[hi]-sorted[lo])
+}
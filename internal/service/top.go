@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+)
+
+// MaxTopLimit — наибольшее N в запросе топа баннеров.
+const MaxTopLimit = 1000
+
+var ErrInvalidTopLimit = errors.New("invalid top limit")
+
+// TopQuery — N баннеров с наибольшим числом кликов за [From, To).
+type TopQuery struct {
+	From, To time.Time
+	Limit    int
+	// Include — баннеры, чьи суммы нужны независимо от места в топе
+	// (для хранилища: баннеры с несохраненными кликами).
+	Include []int64
+}
+
+// BannerTotal — сумма кликов баннера за диапазон.
+type BannerTotal struct {
+	BannerID int64
+	Clicks   int64
+}
+
+// Top implements StatsPort: топ баннеров по кликам из БД вместе с несохраненными кликами
+// агрегатора. Границы диапазона выравниваются вниз до минуты, его длина ограничена
+// лимитом гранулярности day.
+func (s *Stats) Top(ctx context.Context, q TopQuery) (*entity.TopResponse, error) {
+	if q.Limit <= 0 || q.Limit > MaxTopLimit {
+		return nil, ErrInvalidTopLimit
+	}
+	if maxDays := s.limits[GranularityDay]; maxDays > 0 && q.To.Sub(q.From) > time.Hour*24*time.Duration(maxDays) {
+		return nil, ErrRangeTooLarge
+	}
+	q.From, q.To = minuteUTC(q.From), minuteUTC(q.To)
+
+	totals, err := s.topTotals(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Clicks != totals[j].Clicks {
+			return totals[i].Clicks > totals[j].Clicks
+		}
+		return totals[i].BannerID < totals[j].BannerID
+	})
+	if len(totals) > q.Limit {
+		totals = totals[:q.Limit]
+	}
+	resp := &entity.TopResponse{Top: make([]entity.TopBanner, 0, len(totals))}
+	for _, t := range totals {
+		resp.Top = append(resp.Top, entity.TopBanner{BannerID: t.BannerID, Clicks: t.Clicks})
+	}
+	return resp, nil
+}
+
+// topTotals читает суммы кандидатов в топ: первых q.Limit баннеров БД и всех баннеров
+// с несохраненными кликами (только они могут обойти баннеры из топа БД).
+// Согласование с flush — как в queryWithPending.
+func (s *Stats) topTotals(ctx context.Context, q TopQuery) ([]BannerTotal, error) {
+	if s.pending == nil {
+		return s.reader.QueryTop(ctx, q)
+	}
+	for attempt := 0; attempt < overlayAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+			}
+		}
+		gen := s.pending.FlushGen()
+		if gen%2 == 1 {
+			continue
+		}
+		mem, genMem := s.pending.PendingTotals(q.From, q.To)
+		if genMem != gen {
+			continue
+		}
+		dq := q
+		dq.Include = make([]int64, 0, len(mem))
+		for id := range mem {
+			dq.Include = append(dq.Include, id)
+		}
+		sort.Slice(dq.Include, func(i, j int) bool { return dq.Include[i] < dq.Include[j] })
+		rows, err := s.reader.QueryTop(ctx, dq)
+		if err != nil {
+			return nil, err
+		}
+		if s.pending.FlushGen() != gen {
+			continue
+		}
+		for i := range rows {
+			rows[i].Clicks += mem[rows[i].BannerID]
+			delete(mem, rows[i].BannerID)
+		}
+		for id, n := range mem {
+			rows = append(rows, BannerTotal{BannerID: id, Clicks: n})
+		}
+		return rows, nil
+	}
+	return s.reader.QueryTop(ctx, q)
+}
+
+// PendingTotals implements PendingReaderPort: несохраненные клики за [from, to) по баннерам.
+func (a *Aggregator) PendingTotals(from, to time.Time) (map[int64]int64, uint64) {
+	lo, hi := bucket(from), bucket(to)
+	out := make(map[int64]int64)
+	for i := range a.shards {
+		sh := &a.shards[i]
+		sh.mu.Lock()
+		sh.each(func(k key, v counts) {
+			if v.clicks > 0 && k.minute >= lo && k.minute < hi {
+				out[k.banner] += v.clicks
+			}
+		})
+		sh.mu.Unlock()
+	}
+	return out, a.flushGen.Load()
+}
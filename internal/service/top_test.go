@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dayanaadylkhanova/click-counter/internal/entity"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestStats_Top_MergesPendingClicks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := NewMockStatsReaderPort(ctrl)
+	pending := NewMockPendingReaderPort(ctrl)
+	stats := NewStats(reader, pending, nil, 90, nil, 0)
+
+	to := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+	from := to.Add(-time.Hour)
+
+	gomock.InOrder(
+		pending.EXPECT().FlushGen().Return(uint64(2)),
+		pending.EXPECT().PendingTotals(from, to).Return(map[int64]int64{3: 6, 2: 1}, uint64(2)),
+		reader.EXPECT().QueryTop(gomock.Any(), TopQuery{From: from, To: to, Limit: 2, Include: []int64{2, 3}}).
+			Return([]BannerTotal{{BannerID: 1, Clicks: 10}, {BannerID: 2, Clicks: 8}, {BannerID: 3, Clicks: 5}}, nil),
+		pending.EXPECT().FlushGen().Return(uint64(2)),
+	)
+
+	resp, err := stats.Top(context.Background(), TopQuery{From: from.Add(30 * time.Second), To: to.Add(30 * time.Second), Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []entity.TopBanner{{BannerID: 3, Clicks: 11}, {BannerID: 1, Clicks: 10}}
+	if len(resp.Top) != len(want) || resp.Top[0] != want[0] || resp.Top[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, resp.Top)
+	}
+}
+
+func TestAggregator_PendingTotals(t *testing.T) {
+	agg := NewAggregator(zap.NewNop(), nil, 4, time.Hour)
+	now := time.Date(2025, 10, 19, 12, 0, 0, 0, time.UTC)
+	agg.Add(Event{BannerID: 1, TS: now, Count: 2})
+	agg.Add(Event{BannerID: 1, TS: now, Count: 1, Dims: "country=KZ"})
+	agg.Add(Event{BannerID: 2, TS: now.Add(-time.Hour), Count: 5})
+	agg.Add(Event{BannerID: 3, TS: now, Count: 7, Kind: KindImpression})
+
+	got, _ := agg.PendingTotals(now.Add(-time.Minute), now.Add(time.Minute))
+	if len(got) != 1 || got[1] != 3 {
+		t.Fatalf("expected only banner 1 with 3 clicks, got %v", got)
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/axiomhq/hyperloglog"
+	"go.uber.org/zap"
+)
+
+// VisitorHash — стабильный 64-битный хэш идентификатора посетителя для HLL
+// (FNV-1a с финализатором murmur3 для равномерного распределения битов).
+func VisitorHash(id string) uint64 {
+	if id == "" {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(id))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	if x == 0 {
+		x = 1
+	}
+	return x
+}
+
+func (k skey) ts() time.Time { return time.Unix(k.minute*60, 0).UTC() }
+
+func (a *Aggregator) setInflight(sk []map[skey]*hyperloglog.Sketch) {
+	a.inflightMu.Lock()
+	a.inflight = sk
+	a.inflightMu.Unlock()
+}
+
+// restoreSketches возвращает незаписанные скетчи в шарды, сливая их с новыми.
+func (a *Aggregator) restoreSketches(sk []map[skey]*hyperloglog.Sketch) {
+	for i := range sk {
+		if sk[i] == nil {
+			continue
+		}
+		sh := &a.shards[i]
+		sh.mu.Lock()
+		for k, hll := range sk[i] {
+			if cur := sh.sketches[k]; cur != nil {
+				_ = hll.Merge(cur)
+				a.keys.Add(-1)
+			}
+			sh.sketches[k] = hll
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// sketchCount — число скетчей снапшота.
+func sketchCount(sk []map[skey]*hyperloglog.Sketch) int {
+	var n int
+	for i := range sk {
+		n += len(sk[i])
+	}
+	return n
+}
+
+func (a *Aggregator) sketchRowsOf(sk []map[skey]*hyperloglog.Sketch) []SketchRow {
+	var rows []SketchRow
+	for i := range sk {
+		for k, hll := range sk[i] {
+			b, err := hll.MarshalBinary()
+			if err != nil {
+				a.log.Warn("sketch marshal failed", zap.Error(err))
+				continue
+			}
+			rows = append(rows, SketchRow{BannerID: k.banner, TS: k.ts(), Sketch: b})
+		}
+	}
+	return rows
+}
+
+// PendingSketches implements PendingReaderPort
+func (a *Aggregator) PendingSketches(ids []int64, from, to time.Time) []SketchRow {
+	lo, hi, want := bucket(from), bucket(to), bannerSet(ids)
+	match := func(k skey) bool {
+		_, ok := want[k.banner]
+		return ok && k.minute >= lo && k.minute < hi
+	}
+	var rows []SketchRow
+	collect := func(m map[skey]*hyperloglog.Sketch) {
+		for k, hll := range m {
+			if !match(k) {
+				continue
+			}
+			if b, err := hll.MarshalBinary(); err == nil {
+				rows = append(rows, SketchRow{BannerID: k.banner, TS: k.ts(), Sketch: b})
+			}
+		}
+	}
+	for i := range a.shards {
+		sh := &a.shards[i]
+		sh.mu.Lock()
+		collect(sh.sketches)
+		sh.mu.Unlock()
+	}
+	a.inflightMu.Lock()
+	for i := range a.inflight {
+		collect(a.inflight[i])
+	}
+	a.inflightMu.Unlock()
+	return rows
+}
+
+// mergeSketches сливает скетчи по бакетам гранулярности g и в общий итог.
+// Некорректные скетчи пропускаются.
+func mergeSketches(rows []SketchRow, b Buckets) (map[time.Time]*hyperloglog.Sketch, *hyperloglog.Sketch) {
+	buckets := make(map[time.Time]*hyperloglog.Sketch)
+	total := hyperloglog.New()
+	for _, r := range rows {
+		hll := hyperloglog.New()
+		if err := hll.UnmarshalBinary(r.Sketch); err != nil {
+			continue
+		}
+		ts := b.Truncate(r.TS)
+		if b := buckets[ts]; b != nil {
+			_ = b.Merge(hll)
+		} else {
+			buckets[ts] = hll.Clone()
+		}
+		_ = total.Merge(hll)
+	}
+	return buckets, total
+}
+
+// MergeSketch сливает сериализованные скетчи; пустой dst — пустой скетч.
+func MergeSketch(dst, src []byte) ([]byte, error) {
+	a := hyperloglog.New()
+	if len(dst) > 0 {
+		if err := a.UnmarshalBinary(dst); err != nil {
+			return nil, err
+		}
+	}
+	b := hyperloglog.New()
+	if err := b.UnmarshalBinary(src); err != nil {
+		return nil, err
+	}
+	if err := a.Merge(b); err != nil {
+		return nil, err
+	}
+	return a.MarshalBinary()
+}
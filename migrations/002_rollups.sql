@@ -0,0 +1,30 @@
+-- Часовые и дневные роллапы banner_clicks (границы бакетов в UTC)
+CREATE TABLE IF NOT EXISTS banner_clicks_hourly (
+  banner_id BIGINT      NOT NULL,
+  ts        TIMESTAMPTZ NOT NULL,  -- начало часа (UTC)
+  cnt       BIGINT      NOT NULL,
+  PRIMARY KEY (banner_id, ts)
+);
+
+CREATE TABLE IF NOT EXISTS banner_clicks_daily (
+  banner_id BIGINT      NOT NULL,
+  ts        TIMESTAMPTZ NOT NULL,  -- начало суток (UTC)
+  cnt       BIGINT      NOT NULL,
+  PRIMARY KEY (banner_id, ts)
+);
+
+-- Первичное заполнение только пустых роллапов, как в Store.Init; ON CONFLICT без списка
+-- колонок подходит и к ключу (banner_id, ts, dims), если Init уже создал таблицы
+INSERT INTO banner_clicks_hourly (banner_id, ts, cnt)
+SELECT banner_id, date_trunc('hour', ts AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', SUM(cnt)
+FROM banner_clicks
+WHERE NOT EXISTS (SELECT 1 FROM banner_clicks_hourly)
+GROUP BY 1, 2
+ON CONFLICT DO NOTHING;
+
+INSERT INTO banner_clicks_daily (banner_id, ts, cnt)
+SELECT banner_id, date_trunc('day', ts AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', SUM(cnt)
+FROM banner_clicks
+WHERE NOT EXISTS (SELECT 1 FROM banner_clicks_daily)
+GROUP BY 1, 2
+ON CONFLICT DO NOTHING;
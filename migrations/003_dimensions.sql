@@ -0,0 +1,16 @@
+-- Измерения кликов (country, device, ...): входят в первичный ключ агрегатов.
+-- Повторный запуск и база, подготовленная Store.Init, ничего не меняют.
+ALTER TABLE banner_clicks        ADD COLUMN IF NOT EXISTS dims JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE banner_clicks_hourly ADD COLUMN IF NOT EXISTS dims JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE banner_clicks_daily  ADD COLUMN IF NOT EXISTS dims JSONB NOT NULL DEFAULT '{}';
+
+DO $$
+DECLARE t TEXT;
+BEGIN
+  FOREACH t IN ARRAY ARRAY['banner_clicks', 'banner_clicks_hourly', 'banner_clicks_daily'] LOOP
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = t || '_dims_pkey') THEN
+      EXECUTE format('ALTER TABLE %I DROP CONSTRAINT IF EXISTS %I', t, t || '_pkey');
+      EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I PRIMARY KEY (banner_id, ts, dims)', t, t || '_dims_pkey');
+    END IF;
+  END LOOP;
+END $$;
@@ -0,0 +1,21 @@
+-- HLL-скетчи уникальных посетителей: минутные и роллапы (границы бакетов в UTC)
+CREATE TABLE IF NOT EXISTS banner_uniques (
+  banner_id BIGINT      NOT NULL,
+  ts        TIMESTAMPTZ NOT NULL,  -- начало минуты (UTC)
+  sketch    BYTEA       NOT NULL,  -- сериализованный HyperLogLog (axiomhq/hyperloglog)
+  PRIMARY KEY (banner_id, ts)
+);
+
+CREATE TABLE IF NOT EXISTS banner_uniques_hourly (
+  banner_id BIGINT      NOT NULL,
+  ts        TIMESTAMPTZ NOT NULL,  -- начало часа (UTC)
+  sketch    BYTEA       NOT NULL,
+  PRIMARY KEY (banner_id, ts)
+);
+
+CREATE TABLE IF NOT EXISTS banner_uniques_daily (
+  banner_id BIGINT      NOT NULL,
+  ts        TIMESTAMPTZ NOT NULL,  -- начало суток (UTC)
+  sketch    BYTEA       NOT NULL,
+  PRIMARY KEY (banner_id, ts)
+);
@@ -0,0 +1,4 @@
+-- Показы баннеров: отдельный счетчик рядом с кликами (CTR = cnt / imps)
+ALTER TABLE banner_clicks        ADD COLUMN IF NOT EXISTS imps BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE banner_clicks_hourly ADD COLUMN IF NOT EXISTS imps BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE banner_clicks_daily  ADD COLUMN IF NOT EXISTS imps BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Реестр баннеров: адрес перехода для редиректа /r/{bannerID}
+-- (макросы {banner_id}, {ts}, {request_id}, {<измерение>} подставляются при переходе)
+CREATE TABLE IF NOT EXISTS banners (
+  id         BIGINT      PRIMARY KEY,
+  target_url TEXT        NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,5 @@
+-- Управление реестром баннеров: имя и архивация (архивные баннеры обрабатываются
+-- по политике BANNER_UNKNOWN_POLICY, как неизвестные)
+ALTER TABLE banners ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '';
+ALTER TABLE banners ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+ALTER TABLE banners ALTER COLUMN target_url SET DEFAULT '';
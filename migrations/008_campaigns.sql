@@ -0,0 +1,20 @@
+-- Кампании (группы баннеров) и история членства баннеров в них:
+-- баннер входит в кампанию на [valid_from, valid_to), valid_to NULL — по сей день
+CREATE TABLE IF NOT EXISTS campaigns (
+  id         BIGINT      PRIMARY KEY,
+  name       TEXT        NOT NULL DEFAULT '',
+  advertiser TEXT        NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS campaign_banners (
+  campaign_id BIGINT      NOT NULL REFERENCES campaigns (id),
+  banner_id   BIGINT      NOT NULL,
+  valid_from  TIMESTAMPTZ NOT NULL,
+  valid_to    TIMESTAMPTZ,
+  PRIMARY KEY (campaign_id, banner_id, valid_from)
+);
+
+-- Не больше одного незавершенного членства баннера в кампании
+CREATE UNIQUE INDEX IF NOT EXISTS idx_campaign_banners_open ON campaign_banners (campaign_id, banner_id) WHERE valid_to IS NULL;
+CREATE INDEX IF NOT EXISTS idx_campaign_banners_bid ON campaign_banners (banner_id);
@@ -0,0 +1,5 @@
+-- Покрывающие индексы по времени: топ баннеров (/top) агрегирует все баннеры диапазона,
+-- середину диапазона читая из роллапов
+CREATE INDEX IF NOT EXISTS idx_banner_clicks_ts ON banner_clicks (ts) INCLUDE (banner_id, cnt);
+CREATE INDEX IF NOT EXISTS idx_banner_clicks_hourly_ts ON banner_clicks_hourly (ts) INCLUDE (banner_id, cnt);
+CREATE INDEX IF NOT EXISTS idx_banner_clicks_daily_ts ON banner_clicks_daily (ts) INCLUDE (banner_id, cnt);
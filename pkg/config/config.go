@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,7 +16,54 @@ type Config struct {
 	Shards           int
 	MaxCPU           int
 	ReadMaxRangeDays int
-	ShutdownWait     time.Duration
+	// ReadMaxRangeDaysBy — лимиты диапазона по гранулярностям, формат "minute=7,day=732".
+	ReadMaxRangeDaysBy map[string]int
+	// StatsMaxBanners — лимит баннеров в одном запросе POST /stats.
+	StatsMaxBanners int
+	// BatchMaxItemCount — наибольший count одного элемента POST /counter/batch.
+	BatchMaxItemCount int64
+	ShutdownWait      time.Duration
+	WALDir            string
+	WALSyncEvery      time.Duration
+	WALSyncBatch      int
+	EventLateness     time.Duration
+	EventFutureSkew   time.Duration
+	EventLatePolicy   string
+	// Dimensions — измерения кликов и лимиты их кардинальности, формат "country=250,device=8".
+	Dimensions map[string]int
+	// Источники идентификатора посетителя для уникальных (заголовок, cookie, хэш IP+UA).
+	VisitorHeader   string
+	VisitorCookie   string
+	VisitorHashIPUA bool
+	// BannersRefreshEvery — период перечитывания реестра баннеров из БД.
+	BannersRefreshEvery time.Duration
+	// BannerUnknownPolicy — события неизвестных и архивных баннеров: accept, reject, quarantine.
+	BannerUnknownPolicy string
+	// AdminToken — bearer-токен для изменения баннеров и кампаний; пусто — изменение через HTTP запрещено.
+	AdminToken string
+	// TracingEndpoint — адрес OTLP/HTTP-коллектора ("localhost:4318"); пусто — трассировка выключена.
+	TracingEndpoint    string
+	TracingInsecure    bool
+	TracingSampleRatio float64
+	// Пороги /readyz: давность последнего успешного flush, несохраненные ключи агрегатора
+	// (0 — без ограничения) и таймаут проверки соединения с БД.
+	ReadyMaxFlushAge    time.Duration
+	ReadyMaxPendingKeys int
+	ReadyDBTimeout      time.Duration
+	// AggMaxPendingKeys — лимит несохраненных ключей агрегатора (0 — без ограничения);
+	// AggOverflowPolicy — что делать сверх лимита: reject, spill (в AggSpillDir), drop_oldest;
+	// AggSpillDrainBatch — сколько записей spill возвращать в шарды за один flush.
+	AggMaxPendingKeys  int
+	AggOverflowPolicy  string
+	AggSpillDir        string
+	AggSpillDrainBatch int
+	// Повторы flush после ошибок: экспоненциальная задержка от FlushBackoffBase (0 — повтор
+	// на каждом тике) до FlushBackoffMax (0 — без ограничения); после FlushBreakerThreshold
+	// ошибок подряд (0 — без предохранителя) попытки приостанавливаются на FlushBreakerCooldown.
+	FlushBackoffBase      time.Duration
+	FlushBackoffMax       time.Duration
+	FlushBreakerThreshold int
+	FlushBreakerCooldown  time.Duration
 }
 
 func Parse() (*Config, error) {
@@ -28,7 +76,68 @@ func Parse() (*Config, error) {
 	c.Shards = mustInt(getenv("SHARDS", "64"))
 	c.MaxCPU = mustInt(getenv("MAX_CPU", "0"))
 	c.ReadMaxRangeDays = mustInt(getenv("READ_MAX_RANGE_DAYS", "90"))
+	limits, err := parseIntMap(getenv("READ_MAX_RANGE_DAYS_BY", ""))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("READ_MAX_RANGE_DAYS_BY: %w", err))
+	}
+	c.ReadMaxRangeDaysBy = limits
+	c.StatsMaxBanners = mustInt(getenv("STATS_MAX_BANNERS", "100"))
+	c.BatchMaxItemCount = int64(mustInt(getenv("BATCH_MAX_ITEM_COUNT", "1000")))
 	c.ShutdownWait = mustDuration(getenv("SHUTDOWN_WAIT", "5s"))
+	c.WALDir = getenv("WAL_DIR", "")
+	c.WALSyncEvery = mustDuration(getenv("WAL_SYNC_EVERY", "100ms"))
+	c.WALSyncBatch = mustInt(getenv("WAL_SYNC_BATCH", "512"))
+	c.EventLateness = mustDuration(getenv("EVENT_ALLOWED_LATENESS", "24h"))
+	c.EventFutureSkew = mustDuration(getenv("EVENT_MAX_FUTURE_SKEW", "1m"))
+	c.EventLatePolicy = getenv("EVENT_LATE_POLICY", "reject")
+	dims, err := parseIntMap(getenv("DIMENSIONS", ""))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("DIMENSIONS: %w", err))
+	}
+	for name, limit := range dims {
+		if limit == 0 {
+			errs = append(errs, fmt.Errorf("DIMENSIONS: cardinality limit of %q must be > 0", name))
+		}
+	}
+	c.Dimensions = dims
+	c.VisitorHeader = getenv("VISITOR_HEADER", "X-Visitor-ID")
+	c.VisitorCookie = getenv("VISITOR_COOKIE", "vid")
+	c.VisitorHashIPUA, err = strconv.ParseBool(getenv("VISITOR_HASH_IP_UA", "false"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("VISITOR_HASH_IP_UA must be a boolean"))
+	}
+	c.BannersRefreshEvery = mustDuration(getenv("BANNERS_REFRESH_EVERY", "30s"))
+	c.BannerUnknownPolicy = getenv("BANNER_UNKNOWN_POLICY", "accept")
+	c.AdminToken = getenv("ADMIN_TOKEN", "")
+	c.TracingEndpoint = getenv("TRACING_ENDPOINT", "")
+	c.TracingInsecure, err = strconv.ParseBool(getenv("TRACING_INSECURE", "false"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("TRACING_INSECURE must be a boolean"))
+	}
+	c.TracingSampleRatio, err = strconv.ParseFloat(getenv("TRACING_SAMPLE_RATIO", "1"), 64)
+	if err != nil || c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO must be a number in [0, 1]"))
+	}
+	c.ReadyMaxFlushAge = mustDuration(getenv("READY_MAX_FLUSH_AGE", "1m"))
+	c.ReadyMaxPendingKeys = mustInt(getenv("READY_MAX_PENDING_KEYS", "1000000"))
+	c.ReadyDBTimeout = mustDuration(getenv("READY_DB_TIMEOUT", "1s"))
+	c.AggMaxPendingKeys = mustInt(getenv("AGG_MAX_PENDING_KEYS", "0"))
+	c.AggOverflowPolicy = getenv("AGG_OVERFLOW_POLICY", "reject")
+	c.AggSpillDir = getenv("AGG_SPILL_DIR", "")
+	c.AggSpillDrainBatch = mustInt(getenv("AGG_SPILL_DRAIN_BATCH", "10000"))
+	for _, d := range []struct {
+		dst       *time.Duration
+		name, def string
+	}{
+		{&c.FlushBackoffBase, "FLUSH_BACKOFF_BASE", "1s"},
+		{&c.FlushBackoffMax, "FLUSH_BACKOFF_MAX", "1m"},
+		{&c.FlushBreakerCooldown, "FLUSH_BREAKER_COOLDOWN", "30s"},
+	} {
+		if *d.dst, err = zeroDuration(d.name, d.def); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	c.FlushBreakerThreshold = mustInt(getenv("FLUSH_BREAKER_THRESHOLD", "5"))
 	if c.DatabaseURL == "" {
 		errs = append(errs, fmt.Errorf("DATABASE_URL is required"))
 	}
@@ -38,6 +147,49 @@ func Parse() (*Config, error) {
 	if c.ReadMaxRangeDays < 0 {
 		errs = append(errs, fmt.Errorf("READ_MAX_RANGE_DAYS must be >= 0"))
 	}
+	if c.StatsMaxBanners < 0 {
+		errs = append(errs, fmt.Errorf("STATS_MAX_BANNERS must be >= 0"))
+	}
+	if c.BatchMaxItemCount <= 0 {
+		errs = append(errs, fmt.Errorf("BATCH_MAX_ITEM_COUNT must be > 0"))
+	}
+	if c.ReadyMaxPendingKeys < 0 {
+		errs = append(errs, fmt.Errorf("READY_MAX_PENDING_KEYS must be >= 0"))
+	}
+	if c.WALSyncBatch < 0 {
+		errs = append(errs, fmt.Errorf("WAL_SYNC_BATCH must be >= 0"))
+	}
+	switch c.EventLatePolicy {
+	case "reject", "clamp", "count":
+	default:
+		errs = append(errs, fmt.Errorf("EVENT_LATE_POLICY must be one of reject, clamp, count"))
+	}
+	switch c.BannerUnknownPolicy {
+	case "accept", "reject", "quarantine":
+	default:
+		errs = append(errs, fmt.Errorf("BANNER_UNKNOWN_POLICY must be one of accept, reject, quarantine"))
+	}
+	if c.AggMaxPendingKeys < 0 {
+		errs = append(errs, fmt.Errorf("AGG_MAX_PENDING_KEYS must be >= 0"))
+	}
+	switch c.AggOverflowPolicy {
+	case "reject", "drop_oldest":
+	case "spill":
+		if c.AggSpillDir == "" {
+			errs = append(errs, fmt.Errorf("AGG_SPILL_DIR is required for AGG_OVERFLOW_POLICY=spill"))
+		}
+		if c.AggSpillDrainBatch <= 0 {
+			errs = append(errs, fmt.Errorf("AGG_SPILL_DRAIN_BATCH must be > 0"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("AGG_OVERFLOW_POLICY must be one of reject, spill, drop_oldest"))
+	}
+	if c.FlushBackoffMax > 0 && c.FlushBackoffMax < c.FlushBackoffBase {
+		errs = append(errs, fmt.Errorf("FLUSH_BACKOFF_MAX must be 0 or >= FLUSH_BACKOFF_BASE"))
+	}
+	if c.FlushBreakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("FLUSH_BREAKER_THRESHOLD must be >= 0"))
+	}
 	if len(errs) > 0 {
 		return nil, joinErrs(errs)
 	}
@@ -59,6 +211,33 @@ func mustDuration(s string) time.Duration {
 	return d
 }
 
+// zeroDuration разбирает длительность, для которой 0 — осмысленное значение
+// (в отличие от mustDuration, не подменяет его на 1s); отрицательная длительность — ошибка.
+func zeroDuration(name, def string) (time.Duration, error) {
+	d, err := time.ParseDuration(getenv(name, def))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a duration >= 0", name)
+	}
+	return d, nil
+}
+
+// parseIntMap разбирает список вида "k1=1,k2=2"; значения должны быть >= 0.
+func parseIntMap(s string) (map[string]int, error) {
+	out := map[string]int{}
+	if s == "" {
+		return out, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		n, err := strconv.Atoi(v)
+		if !ok || k == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid entry %q", part)
+		}
+		out[k] = n
+	}
+	return out, nil
+}
+
 func joinErrs(errs []error) error {
 	msg := ""
 	for i, e := range errs {
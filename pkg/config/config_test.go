@@ -14,7 +14,35 @@ func TestParse_DefaultsAndRequired(t *testing.T) {
 	t.Setenv("SHARDS", "")
 	t.Setenv("MAX_CPU", "")
 	t.Setenv("READ_MAX_RANGE_DAYS", "")
+	t.Setenv("STATS_MAX_BANNERS", "")
+	t.Setenv("BATCH_MAX_ITEM_COUNT", "")
 	t.Setenv("SHUTDOWN_WAIT", "")
+	t.Setenv("WAL_DIR", "")
+	t.Setenv("WAL_SYNC_EVERY", "")
+	t.Setenv("WAL_SYNC_BATCH", "")
+	t.Setenv("EVENT_ALLOWED_LATENESS", "")
+	t.Setenv("EVENT_MAX_FUTURE_SKEW", "")
+	t.Setenv("EVENT_LATE_POLICY", "")
+	t.Setenv("VISITOR_HEADER", "")
+	t.Setenv("VISITOR_COOKIE", "")
+	t.Setenv("VISITOR_HASH_IP_UA", "")
+	t.Setenv("BANNERS_REFRESH_EVERY", "")
+	t.Setenv("BANNER_UNKNOWN_POLICY", "")
+	t.Setenv("TRACING_ENDPOINT", "")
+	t.Setenv("TRACING_INSECURE", "")
+	t.Setenv("TRACING_SAMPLE_RATIO", "")
+	t.Setenv("READY_MAX_FLUSH_AGE", "")
+	t.Setenv("READY_MAX_PENDING_KEYS", "")
+	t.Setenv("READY_DB_TIMEOUT", "")
+	t.Setenv("AGG_MAX_PENDING_KEYS", "")
+	t.Setenv("AGG_OVERFLOW_POLICY", "")
+	t.Setenv("AGG_SPILL_DIR", "")
+	t.Setenv("AGG_SPILL_DRAIN_BATCH", "")
+	t.Setenv("ADMIN_TOKEN", "")
+	t.Setenv("FLUSH_BACKOFF_BASE", "")
+	t.Setenv("FLUSH_BACKOFF_MAX", "")
+	t.Setenv("FLUSH_BREAKER_THRESHOLD", "")
+	t.Setenv("FLUSH_BREAKER_COOLDOWN", "")
 
 	cfg, err := Parse()
 	if err != nil {
@@ -35,9 +63,46 @@ func TestParse_DefaultsAndRequired(t *testing.T) {
 	if cfg.ReadMaxRangeDays != 90 {
 		t.Fatalf("default READ_MAX_RANGE_DAYS expected 90, got %d", cfg.ReadMaxRangeDays)
 	}
+	if cfg.StatsMaxBanners != 100 {
+		t.Fatalf("default STATS_MAX_BANNERS expected 100, got %d", cfg.StatsMaxBanners)
+	}
+	if cfg.BatchMaxItemCount != 1000 {
+		t.Fatalf("default BATCH_MAX_ITEM_COUNT expected 1000, got %d", cfg.BatchMaxItemCount)
+	}
 	if cfg.ShutdownWait != 5*time.Second {
 		t.Fatalf("default SHUTDOWN_WAIT expected 5s, got %v", cfg.ShutdownWait)
 	}
+	if cfg.WALDir != "" || cfg.WALSyncEvery != 100*time.Millisecond || cfg.WALSyncBatch != 512 {
+		t.Fatalf("default WAL settings unexpected: %+v", cfg)
+	}
+	if cfg.EventLateness != 24*time.Hour || cfg.EventFutureSkew != time.Minute || cfg.EventLatePolicy != "reject" {
+		t.Fatalf("default event window unexpected: %+v", cfg)
+	}
+	if cfg.VisitorHeader != "X-Visitor-ID" || cfg.VisitorCookie != "vid" || cfg.VisitorHashIPUA {
+		t.Fatalf("default visitor settings unexpected: %+v", cfg)
+	}
+	if cfg.BannersRefreshEvery != 30*time.Second {
+		t.Fatalf("default BANNERS_REFRESH_EVERY expected 30s, got %v", cfg.BannersRefreshEvery)
+	}
+	if cfg.BannerUnknownPolicy != "accept" {
+		t.Fatalf("default BANNER_UNKNOWN_POLICY expected accept, got %q", cfg.BannerUnknownPolicy)
+	}
+	if cfg.TracingEndpoint != "" || cfg.TracingInsecure || cfg.TracingSampleRatio != 1 {
+		t.Fatalf("default tracing settings unexpected: %+v", cfg)
+	}
+	if cfg.ReadyMaxFlushAge != time.Minute || cfg.ReadyMaxPendingKeys != 1000000 || cfg.ReadyDBTimeout != time.Second {
+		t.Fatalf("default readiness thresholds unexpected: %+v", cfg)
+	}
+	if cfg.AggMaxPendingKeys != 0 || cfg.AggOverflowPolicy != "reject" || cfg.AggSpillDir != "" || cfg.AggSpillDrainBatch != 10000 {
+		t.Fatalf("default aggregator limits unexpected: %+v", cfg)
+	}
+	if cfg.AdminToken != "" {
+		t.Fatalf("expected admin api disabled by default, got %q", cfg.AdminToken)
+	}
+	if cfg.FlushBackoffBase != time.Second || cfg.FlushBackoffMax != time.Minute ||
+		cfg.FlushBreakerThreshold != 5 || cfg.FlushBreakerCooldown != 30*time.Second {
+		t.Fatalf("default flush retry policy unexpected: %+v", cfg)
+	}
 }
 
 func TestParse_CustomValues(t *testing.T) {
@@ -48,6 +113,7 @@ func TestParse_CustomValues(t *testing.T) {
 	t.Setenv("SHARDS", "128")
 	t.Setenv("MAX_CPU", "4")
 	t.Setenv("READ_MAX_RANGE_DAYS", "7")
+	t.Setenv("READ_MAX_RANGE_DAYS_BY", "minute=1, day=400")
 	t.Setenv("SHUTDOWN_WAIT", "2s")
 
 	cfg, err := Parse()
@@ -63,6 +129,24 @@ func TestParse_CustomValues(t *testing.T) {
 	if cfg.Shards != 128 || cfg.MaxCPU != 4 || cfg.ReadMaxRangeDays != 7 || cfg.ShutdownWait != 2*time.Second {
 		t.Fatalf("custom numeric/envs not applied: %+v", cfg)
 	}
+	if cfg.ReadMaxRangeDaysBy["minute"] != 1 || cfg.ReadMaxRangeDaysBy["day"] != 400 {
+		t.Fatalf("expected per-granularity limits, got %v", cfg.ReadMaxRangeDaysBy)
+	}
+}
+
+func TestParse_FlushRetryZeroValues(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://u:p@h:5432/db?sslmode=disable")
+	t.Setenv("FLUSH_BACKOFF_BASE", "0")
+	t.Setenv("FLUSH_BACKOFF_MAX", "0")
+	t.Setenv("FLUSH_BREAKER_COOLDOWN", "0s")
+
+	cfg, err := Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.FlushBackoffBase != 0 || cfg.FlushBackoffMax != 0 || cfg.FlushBreakerCooldown != 0 {
+		t.Fatalf("expected zero durations kept, got %+v", cfg)
+	}
 }
 
 func TestParse_Errors(t *testing.T) {
@@ -92,6 +176,153 @@ func TestParse_Errors(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "malformed READ_MAX_RANGE_DAYS_BY",
+			env: map[string]string{
+				"DATABASE_URL":           "postgres://u:p@h:5432/db?sslmode=disable",
+				"READ_MAX_RANGE_DAYS_BY": "minute=7,day",
+			},
+			wantErr: true,
+		},
+		{
+			name: "spill without AGG_SPILL_DIR",
+			env: map[string]string{
+				"DATABASE_URL":        "postgres://u:p@h:5432/db?sslmode=disable",
+				"AGG_OVERFLOW_POLICY": "spill",
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero AGG_SPILL_DRAIN_BATCH with spill",
+			env: map[string]string{
+				"DATABASE_URL":          "postgres://u:p@h:5432/db?sslmode=disable",
+				"AGG_OVERFLOW_POLICY":   "spill",
+				"AGG_SPILL_DIR":         "/tmp/spill",
+				"AGG_SPILL_DRAIN_BATCH": "0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown AGG_OVERFLOW_POLICY",
+			env: map[string]string{
+				"DATABASE_URL":        "postgres://u:p@h:5432/db?sslmode=disable",
+				"AGG_OVERFLOW_POLICY": "block",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative FLUSH_BREAKER_THRESHOLD",
+			env: map[string]string{
+				"DATABASE_URL":            "postgres://u:p@h:5432/db?sslmode=disable",
+				"FLUSH_BREAKER_THRESHOLD": "-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative FLUSH_BREAKER_COOLDOWN",
+			env: map[string]string{
+				"DATABASE_URL":           "postgres://u:p@h:5432/db?sslmode=disable",
+				"FLUSH_BREAKER_COOLDOWN": "-1s",
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed FLUSH_BACKOFF_BASE",
+			env: map[string]string{
+				"DATABASE_URL":       "postgres://u:p@h:5432/db?sslmode=disable",
+				"FLUSH_BACKOFF_BASE": "soon",
+			},
+			wantErr: true,
+		},
+		{
+			name: "FLUSH_BACKOFF_MAX below FLUSH_BACKOFF_BASE",
+			env: map[string]string{
+				"DATABASE_URL":       "postgres://u:p@h:5432/db?sslmode=disable",
+				"FLUSH_BACKOFF_BASE": "10s",
+				"FLUSH_BACKOFF_MAX":  "5s",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative READY_MAX_PENDING_KEYS",
+			env: map[string]string{
+				"DATABASE_URL":           "postgres://u:p@h:5432/db?sslmode=disable",
+				"READY_MAX_PENDING_KEYS": "-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "TRACING_SAMPLE_RATIO out of range",
+			env: map[string]string{
+				"DATABASE_URL":         "postgres://u:p@h:5432/db?sslmode=disable",
+				"TRACING_SAMPLE_RATIO": "1.5",
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero BATCH_MAX_ITEM_COUNT",
+			env: map[string]string{
+				"DATABASE_URL":         "postgres://u:p@h:5432/db?sslmode=disable",
+				"BATCH_MAX_ITEM_COUNT": "0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative STATS_MAX_BANNERS",
+			env: map[string]string{
+				"DATABASE_URL":      "postgres://u:p@h:5432/db?sslmode=disable",
+				"STATS_MAX_BANNERS": "-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative WAL_SYNC_BATCH",
+			env: map[string]string{
+				"DATABASE_URL":   "postgres://u:p@h:5432/db?sslmode=disable",
+				"WAL_SYNC_BATCH": "-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown EVENT_LATE_POLICY",
+			env: map[string]string{
+				"DATABASE_URL":      "postgres://u:p@h:5432/db?sslmode=disable",
+				"EVENT_LATE_POLICY": "drop",
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed VISITOR_HASH_IP_UA",
+			env: map[string]string{
+				"DATABASE_URL":       "postgres://u:p@h:5432/db?sslmode=disable",
+				"VISITOR_HASH_IP_UA": "maybe",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown BANNER_UNKNOWN_POLICY",
+			env: map[string]string{
+				"DATABASE_URL":          "postgres://u:p@h:5432/db?sslmode=disable",
+				"BANNER_UNKNOWN_POLICY": "drop",
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero DIMENSIONS limit",
+			env: map[string]string{
+				"DATABASE_URL": "postgres://u:p@h:5432/db?sslmode=disable",
+				"DIMENSIONS":   "country=0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "AGG_SPILL_DRAIN_BATCH ignored without spill",
+			env: map[string]string{
+				"DATABASE_URL":          "postgres://u:p@h:5432/db?sslmode=disable",
+				"AGG_SPILL_DRAIN_BATCH": "0",
+			},
+			wantErr: false,
+		},
 		{
 			name: "ok minimal",
 			env: map[string]string{
@@ -107,6 +338,9 @@ func TestParse_Errors(t *testing.T) {
 			for _, k := range []string{
 				"DATABASE_URL", "LISTEN_ADDR", "LOG_LEVEL", "FLUSH_EVERY",
 				"SHARDS", "MAX_CPU", "READ_MAX_RANGE_DAYS", "SHUTDOWN_WAIT",
+				"READ_MAX_RANGE_DAYS_BY", "WAL_DIR", "WAL_SYNC_EVERY", "WAL_SYNC_BATCH",
+				"EVENT_ALLOWED_LATENESS", "EVENT_MAX_FUTURE_SKEW", "EVENT_LATE_POLICY",
+				"DIMENSIONS",
 			} {
 				_ = os.Unsetenv(k)
 			}